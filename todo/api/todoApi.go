@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ToDoApi exposes a todo database over a REST API.  It works on the same
+// database file as the CLI, and picks up changes the CLI makes while the
+// server is running.
+type ToDoApi struct {
+	db *db.ToDo
+}
+
+func NewToDoApi(dbFile string) (*ToDoApi, error) {
+	dbHandler, err := db.New(dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ToDoApi{
+		db: dbHandler,
+	}, nil
+}
+
+// Unlock sets the function asked for the passphrase of an encrypted
+// database and opens the database straight away, so a missing or wrong
+// passphrase is reported before any request is served rather than on
+// every one.  A database that is not encrypted never asks.
+func (t *ToDoApi) Unlock(passphrase func() (string, error)) error {
+	t.db.SetPassphraseFunc(passphrase)
+	_, err := t.db.IsEncrypted()
+	return err
+}
+
+// AddHook registers a hook called after every change made through the
+// api, see db.ToDo.AddHook
+func (t *ToDoApi) AddHook(fn db.Hook, events ...db.HookEvent) {
+	t.db.AddHook(fn, events...)
+}
+
+// Close releases the database used by the api
+func (t *ToDoApi) Close() error {
+	return t.db.Close()
+}
+
+// ListItems returns the items matching the filters given as query
+// parameters, for example /todo-api/items?done=false&tag=work&sort=due
+func (t *ToDoApi) ListItems(c *gin.Context) {
+	q, err := queryFromParams(c)
+	if err != nil {
+		log.Println("Error parsing item query ", err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := t.db.FindContext(c.Request.Context(), q)
+	if err != nil {
+		log.Println("Error retrieving items ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+// ListLists returns every list in the database with counts of its items
+func (t *ToDoApi) ListLists(c *gin.Context) {
+	lists, err := t.db.Lists()
+	if err != nil {
+		log.Println("Error retrieving lists ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, lists)
+}
+
+func (t *ToDoApi) GetItem(c *gin.Context) {
+	id, ok := itemIdParam(c)
+	if !ok {
+		return
+	}
+
+	item, err := t.db.GetItemContext(c.Request.Context(), id)
+	if err != nil {
+		log.Println("Error getting item ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// AddItem creates a new item from the JSON body.  The database assigns
+// the id, any id in the body is ignored.
+func (t *ToDoApi) AddItem(c *gin.Context) {
+	var newItem db.ToDoItem
+
+	if err := c.ShouldBindJSON(&newItem); err != nil {
+		log.Println("Error binding item json ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	id, err := t.db.AddItemContext(c.Request.Context(), newItem)
+	if err != nil {
+		log.Println("Error adding item ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	t.respondWithItem(c, id)
+}
+
+// UpdateItem applies the JSON body to an existing item.  Fields missing
+// from the body keep their current value.
+func (t *ToDoApi) UpdateItem(c *gin.Context) {
+	id, ok := itemIdParam(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		log.Println("Error reading item json ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	//The fields in the body are laid over the item as it is in the
+	//database, read and written under the same lock so no change made in
+	//between is lost
+	var bindErr error
+	err = t.db.UpdateItemFuncContext(c.Request.Context(), id, func(item *db.ToDoItem) error {
+		bindErr = binding.JSON.BindBody(body, item)
+		return bindErr
+	})
+	if bindErr != nil {
+		log.Println("Error binding item json ", bindErr)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("Error updating item ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	t.respondWithItem(c, id)
+}
+
+func (t *ToDoApi) DeleteItem(c *gin.Context) {
+	id, ok := itemIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := t.db.DeleteItemContext(c.Request.Context(), id); err != nil {
+		log.Println("Error deleting item ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// SetItemDone sets the done status of an item to the :status path
+// parameter (true or false).  Add ?force=true to mark an item done even
+// though it is blocked or has open subtasks.
+func (t *ToDoApi) SetItemDone(c *gin.Context) {
+	id, ok := itemIdParam(c)
+	if !ok {
+		return
+	}
+
+	status, err := strconv.ParseBool(c.Param("status"))
+	if err != nil {
+		log.Println("Error converting done status to bool ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if status && c.Query("force") == "true" {
+		err = t.db.ForceItemDoneContext(c.Request.Context(), id)
+	} else {
+		err = t.db.ChangeItemDoneStatusContext(c.Request.Context(), id, status)
+	}
+	if err != nil {
+		log.Println("Error changing item done status ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	t.respondWithItem(c, id)
+}
+
+// ToggleItemDone flips the done status of an item
+func (t *ToDoApi) ToggleItemDone(c *gin.Context) {
+	id, ok := itemIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := t.db.ToggleItemDoneContext(c.Request.Context(), id); err != nil {
+		log.Println("Error changing item done status ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	t.respondWithItem(c, id)
+}
+
+func (t *ToDoApi) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK,
+		gin.H{
+			"status":    "ok",
+			"version":   "1.0.0",
+			"timestamp": time.Now(),
+		})
+}
+
+// respondWithItem sends back the current version of an item after a change
+func (t *ToDoApi) respondWithItem(c *gin.Context, id int) {
+	item, err := t.db.GetItemContext(c.Request.Context(), id)
+	if err != nil {
+		log.Println("Error getting item ", err)
+		abortWithDbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// abortWithDbError ends a request that the database refused, picking the
+// status from the kind of error.  Errors the client can do something
+// about come with the message in the body, anything we do not recognise
+// is a server error.
+func abortWithDbError(c *gin.Context, err error) {
+	var blocked *db.BlockedError
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		c.AbortWithStatus(http.StatusNotFound)
+	case errors.As(err, &blocked), errors.Is(err, db.ErrDuplicateID), errors.Is(err, db.ErrConflict), errors.Is(err, db.ErrNothingToUndo):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, db.ErrInvalidItem):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, db.ErrNotSupported):
+		c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, db.ErrLockTimeout), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+	default:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+// itemIdParam reads the :id path parameter.  If it is not a valid id the
+// request is aborted and ok is false.
+func itemIdParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Println("Error converting item id to int ", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// queryFromParams builds a database query from the request's query
+// parameters.  They mirror the flags of the CLI's ls command:
+//
+//	list=  done=true|false  archived=true|false  tag=a,b  priority=high,medium  due-before=  due-after=
+//	search=  regex=  sort=id|due|priority|title|created|updated  reverse=true
+//	limit=  offset=
+func queryFromParams(c *gin.Context) (db.Query, error) {
+	var q db.Query
+	var err error
+
+	if list := c.Query("list"); list != "" {
+		if q.List, err = db.ParseListName(list); err != nil {
+			return q, err
+		}
+	}
+
+	if done, ok := c.GetQuery("done"); ok {
+		value, err := strconv.ParseBool(done)
+		if err != nil {
+			return q, errors.New("done must be true or false")
+		}
+		q.Done = &value
+	}
+
+	//Like ls, only live items are listed unless the archive is asked for
+	archived := false
+	if param, ok := c.GetQuery("archived"); ok {
+		if archived, err = strconv.ParseBool(param); err != nil {
+			return q, errors.New("archived must be true or false")
+		}
+	}
+	q.Archived = &archived
+
+	q.Tags = listParam(c, "tag")
+
+	for _, p := range listParam(c, "priority") {
+		priority, err := db.ParsePriority(p)
+		if err != nil {
+			return q, err
+		}
+		q.Priorities = append(q.Priorities, priority)
+	}
+
+	if q.DueBefore, err = db.ParseDate(c.Query("due-before")); err != nil {
+		return q, err
+	}
+	if q.DueAfter, err = db.ParseDate(c.Query("due-after")); err != nil {
+		return q, err
+	}
+
+	q.Title = c.Query("search")
+	if expr := c.Query("regex"); expr != "" {
+		if q.TitleRegex, err = regexp.Compile(expr); err != nil {
+			return q, err
+		}
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		if q.SortBy, err = db.ParseSortKey(sort); err != nil {
+			return q, err
+		}
+	}
+	if reverse := c.Query("reverse"); reverse != "" {
+		if q.Reverse, err = strconv.ParseBool(reverse); err != nil {
+			return q, errors.New("reverse must be true or false")
+		}
+	}
+
+	if q.Limit, err = intParam(c, "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = intParam(c, "offset"); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// listParam returns every value of a query parameter, which may be given
+// several times and/or as a comma separated list
+func listParam(c *gin.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryArray(name) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+func intParam(c *gin.Context, name string) (int, error) {
+	param := c.Query(name)
+	if param == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 0 {
+		return 0, errors.New(name + " must be a positive number")
+	}
+
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	addDoneFlag  bool
+	addListFlag  string
+	addItemFlags itemFlags
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <title>",
+	Short: "Add an item to the database",
+	Long: `Add a new item to the database.  Everything after the flags is the
+item's title, so quoting it is optional.
+
+The database gives the item the next id in its sequence and the new id
+is printed.  Items go on the default list unless --list says otherwise.`,
+	Example: `  todo add "Buy milk"
+  todo add Learn Kubernetes --due 2023-10-01 --priority high --tag school
+  todo add --list work Write the report
+  todo add Book hotel --parent 3 --blocked-by 4
+  todo add Take out the bins --due 2023-10-02 --repeat weekly`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		if err := addItemFlags.parse(c); err != nil {
+			return err
+		}
+
+		list, err := db.ParseListName(addListFlag)
+		if err != nil {
+			return err
+		}
+
+		item := db.ToDoItem{
+			Title:  strings.Join(args, " "),
+			List:   list,
+			IsDone: addDoneFlag,
+		}
+		if err := addItemFlags.apply(c, &item); err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			id, err := todo.AddItem(item)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Added item", id)
+			return nil
+		})
+	},
+}
+
+func init() {
+	addCmd.Flags().BoolVar(&addDoneFlag, "done", false, "Add the item already marked as done")
+	addCmd.Flags().StringVarP(&addListFlag, "list", "l", db.DefaultList, "List to add the item to")
+	fromConfig(addCmd.Flags(), "list", "list")
+	addItemFlags.register(addCmd)
+	rootCmd.AddCommand(addCmd)
+}
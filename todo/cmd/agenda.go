@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	agendaListFlag   string
+	agendaTagFlag    []string
+	agendaAllFlag    bool
+	agendaOutputFlag string
+)
+
+var agendaCmd = &cobra.Command{
+	Use:   "agenda",
+	Short: "Show the pending items by when they are due",
+	Long: `Show the items that are not done yet grouped by when they are due:
+overdue, today, this week (the seven days after today) and later.  An
+item due on a day without a time is overdue once that day is over.
+Items without a due date are left out unless --all is given.
+
+Archived items are never shown.  With --output json or yaml the agenda
+is a single document with a list of items per group, to feed to another
+tool.`,
+	Example: `  todo agenda
+  todo agenda --list work --all
+  todo agenda --output json`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		var filter db.Query
+		if agendaListFlag != "" {
+			list, err := db.ParseListName(agendaListFlag)
+			if err != nil {
+				return err
+			}
+			filter.List = list
+		}
+		filter.Tags = agendaTagFlag
+
+		renderer, err := newRenderer(agendaOutputFlag)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			agenda, err := todo.Agenda(filter, time.Now())
+			if err != nil {
+				return err
+			}
+			if !agendaAllFlag {
+				agenda.NoDue = nil
+			}
+
+			if doc, ok := renderer.(render.DocumentRenderer); ok {
+				return doc.RenderDocument(os.Stdout, agenda)
+			}
+
+			return printAgenda(renderer, agenda)
+		})
+	},
+}
+
+func init() {
+	flags := agendaCmd.Flags()
+	flags.StringVarP(&agendaListFlag, "list", "l", "", "Only show items on this list (default all lists)")
+	flags.StringSliceVarP(&agendaTagFlag, "tag", "t", nil, "Only show items with this tag (repeat to require several)")
+	flags.BoolVarP(&agendaAllFlag, "all", "a", false, "Also show the items without a due date")
+	addOutputFlag(agendaCmd, &agendaOutputFlag)
+	rootCmd.AddCommand(agendaCmd)
+}
+
+func printAgenda(renderer render.Renderer, agenda db.Agenda) error {
+	sections := []struct {
+		title string
+		items []db.ToDoItem
+	}{
+		{"Overdue", agenda.Overdue},
+		{"Today", agenda.Today},
+		{"This week", agenda.ThisWeek},
+		{"Later", agenda.Later},
+		{"No due date", agenda.NoDue},
+	}
+
+	shown := 0
+	for _, s := range sections {
+		if len(s.items) == 0 {
+			continue
+		}
+		if shown > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d)\n", s.title, len(s.items))
+		if err := renderer.RenderItems(os.Stdout, s.items); err != nil {
+			return err
+		}
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Println("Nothing is due")
+	}
+	return nil
+}
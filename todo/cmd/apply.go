@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var applyDryRunFlag bool
+
+// applyOp is one entry of the file read by apply.  Which of the other
+// fields are used depends on Op.
+type applyOp struct {
+	Op    string          `json:"op"`
+	Id    int             `json:"id"`
+	Ids   []int           `json:"ids"`
+	List  string          `json:"list"`
+	Force bool            `json:"force"`
+	Item  json.RawMessage `json:"item"`
+}
+
+// errDryRun is returned from the batch to throw its changes away
+var errDryRun = errors.New("dry run")
+
+var applyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Make many changes at once from a JSON file of operations",
+	Long: `Read a JSON array of operations and apply all of them in one go.  Either
+every operation succeeds or the database is left untouched, and one undo
+reverts the lot.  Use - to read standard input.
+
+Each operation is an object with an "op" field:
+
+  {"op": "add", "item": {"title": "Buy milk", "tags": ["shopping"]}}
+  {"op": "update", "id": 3, "item": {"priority": "high"}}
+  {"op": "delete", "id": 4}
+  {"op": "done", "id": 5, "force": true}
+  {"op": "undone", "id": 5}
+  {"op": "move", "ids": [1, 2], "list": "work"}
+
+An update only changes the fields given in "item", the id may be given
+inside "item" instead.  Operations see the changes made by the ones
+before them.  Use --dry-run to check the file without saving anything.`,
+	Example: `  todo apply changes.json
+  todo apply --dry-run changes.json
+  generate-ops | todo apply -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			data, err := readImportFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			var ops []applyOp
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&ops); err != nil {
+				return fmt.Errorf("reading %s: %w", args[0], err)
+			}
+
+			var results []string
+			err = todo.Batch(func(b *db.Batch) error {
+				for i, op := range ops {
+					result, err := applyOne(b, op)
+					if err != nil {
+						return fmt.Errorf("op %d (%s): %w", i+1, op.Op, err)
+					}
+					results = append(results, result)
+				}
+
+				if applyDryRunFlag {
+					return errDryRun
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errDryRun) {
+				return err
+			}
+
+			for _, result := range results {
+				fmt.Println(result)
+			}
+			if applyDryRunFlag {
+				fmt.Printf("Would apply %d operations\n", len(ops))
+			} else {
+				fmt.Printf("Applied %d operations\n", len(ops))
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	applyCmd.Flags().BoolVarP(&applyDryRunFlag, "dry-run", "n", false, "Check the operations without changing the database")
+	rootCmd.AddCommand(applyCmd)
+}
+
+// applyOne runs a single operation against the batch and describes what
+// it did
+func applyOne(b *db.Batch, op applyOp) (string, error) {
+	switch strings.ToLower(op.Op) {
+	case "add":
+		var item db.ToDoItem
+		if err := decodeOpItem(op.Item, &item); err != nil {
+			return "", err
+		}
+		id, err := b.Add(item)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Added item %d", id), nil
+
+	case "update":
+		id := op.Id
+		if len(op.Item) > 0 {
+			var ids struct {
+				Id int `json:"id"`
+			}
+			if err := json.Unmarshal(op.Item, &ids); err != nil {
+				return "", err
+			}
+			if ids.Id != 0 {
+				id = ids.Id
+			}
+		}
+		if id == 0 {
+			return "", errors.New("update needs the id of the item")
+		}
+
+		//Only the fields given in the file replace those of the item
+		item, err := b.Get(id)
+		if err != nil {
+			return "", err
+		}
+		if err := decodeOpItem(op.Item, &item); err != nil {
+			return "", err
+		}
+		item.Id = id
+		if err := b.Update(item); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Updated item %d", id), nil
+
+	case "delete":
+		if err := b.Delete(op.Id); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Deleted item %d", op.Id), nil
+
+	case "done", "undone":
+		value := strings.ToLower(op.Op) == "done"
+		var err error
+		if value && op.Force {
+			err = b.ForceDone(op.Id)
+		} else {
+			err = b.SetDone(op.Id, value)
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Updated item %d done status to %t", op.Id, value), nil
+
+	case "move":
+		ids := op.Ids
+		if op.Id != 0 {
+			ids = append(ids, op.Id)
+		}
+		if len(ids) == 0 {
+			return "", errors.New("move needs the ids of the items")
+		}
+		moved, err := b.Move(ids, op.List)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Moved %d items to %s", len(moved), op.List), nil
+	}
+
+	return "", fmt.Errorf("unknown operation %q, use add, update, delete, done, undone or move", op.Op)
+}
+
+// decodeOpItem reads the item of an operation over the fields already in
+// item
+func decodeOpItem(data json.RawMessage, item *db.ToDoItem) error {
+	if len(data) == 0 {
+		return errors.New("operation needs an item")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(item)
+}
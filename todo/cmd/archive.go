@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	archiveOlderThanFlag string
+	purgeOlderThanFlag   string
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive [<id>...]",
+	Short: "Move done items to the archive",
+	Long: `Move done items out of the live list and into the archive.  Archived
+items are not shown by ls unless --archived is given, but keep their ids
+and can be brought back with restore.  Subtasks go along with their
+parent.
+
+Without ids every done item is archived, use --older-than to only archive
+items completed longer ago than that.`,
+	Example: `  todo archive
+  todo archive --older-than 30d
+  todo archive 3 7`,
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+		before, err := ageCutoff(archiveOlderThanFlag)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 && !before.IsZero() {
+			return fmt.Errorf("--older-than cannot be used with item ids")
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			var archived []int
+			if len(ids) > 0 {
+				archived, err = todo.ArchiveItems(ids)
+			} else {
+				archived, err = todo.ArchiveCompleted(before)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Archived %d items\n", len(archived))
+			printIdList("archived", archived)
+			return nil
+		})
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <id>...",
+	Short: "Bring archived items back to the live list",
+	Long: `Bring archived items back to the live list, along with their subtasks
+and any archived items they are a subtask of.`,
+	Example: `  todo restore 3`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			restored, err := todo.RestoreItems(ids)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Restored %d items\n", len(restored))
+			printIdList("restored", restored)
+			return nil
+		})
+	},
+}
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge [<id>...]",
+	Short: "Delete archived items for good",
+	Long: `Delete archived items from the database.  Without ids every archived
+item is deleted, use --older-than to only delete items archived longer
+ago than that.  Items that are not archived are never purged.`,
+	Example: `  todo purge --older-than 90d
+  todo purge 3`,
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+		before, err := ageCutoff(purgeOlderThanFlag)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 && !before.IsZero() {
+			return fmt.Errorf("--older-than cannot be used with item ids")
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			var purged []int
+			if len(ids) > 0 {
+				purged, err = todo.PurgeItems(ids)
+			} else {
+				purged, err = todo.PurgeArchived(before)
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Purged %d items\n", len(purged))
+			printIdList("purged", purged)
+			return nil
+		})
+	},
+}
+
+func init() {
+	archiveCmd.Flags().StringVar(&archiveOlderThanFlag, "older-than", "", "Only archive items completed longer ago than this, for example 30d, 2w or 12h")
+	purgeCmd.Flags().StringVar(&purgeOlderThanFlag, "older-than", "", "Only purge items archived longer ago than this, for example 90d, 2w or 12h")
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(purgeCmd)
+}
+
+var ageUnits = regexp.MustCompile(`^(\d+)\s*([dw]?)$`)
+
+// ageCutoff turns an age such as 30d, 2w or 12h into the time that long
+// ago.  A plain number counts days, and an empty age gives the zero time,
+// which means no cutoff.
+func ageCutoff(age string) (time.Time, error) {
+	age = strings.ToLower(strings.TrimSpace(age))
+	if age == "" {
+		return time.Time{}, nil
+	}
+
+	if m := ageUnits.FindStringSubmatch(age); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if m[2] == "w" {
+			n *= 7
+		}
+		return time.Now().AddDate(0, 0, -n), nil
+	}
+
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid age %q, use for example 30d, 2w or 12h", age)
+	}
+
+	return time.Now().Add(-d), nil
+}
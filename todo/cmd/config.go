@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/render"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// The config file sets the defaults of the flags most people set the same
+// way every time.  It is YAML, found at TODO_CONFIG or else at
+// todo/config.yaml in the user config directory ($XDG_CONFIG_HOME or
+// ~/.config on Linux):
+//
+//	db: ~/todo/todo.json
+//	sort: due
+//	profile: home
+//	hooks:
+//	  - done=./post-to-chat.sh
+//	profiles:
+//	  work:
+//	    db: ~/work/todo.json
+//	    list: work
+//	    output: plain
+//
+// The settings at the top apply to every profile, a profile overrides
+// them.  Each setting can also come from its own environment variable, a
+// flag beats the environment, which beats the config file, which beats
+// the built in default.
+
+const (
+	configEnv  = "TODO_CONFIG"
+	profileEnv = "TODO_PROFILE"
+
+	// configAnnotation marks the flags whose default comes from a setting,
+	// the value is the name of the setting
+	configAnnotation = "todo-config"
+)
+
+// profileFlag holds the --profile flag shared by every subcommand
+var profileFlag string
+
+// configSettings are the settings of the config file, at the top or in
+// a profile
+type configSettings struct {
+	DB     string   `yaml:"db,omitempty"`
+	List   string   `yaml:"list,omitempty"`
+	Output string   `yaml:"output,omitempty"`
+	Sort   string   `yaml:"sort,omitempty"`
+	Hooks  []string `yaml:"hooks,omitempty"`
+}
+
+// configFile is the layout of the config file
+type configFile struct {
+	configSettings `yaml:",inline"`
+	Profile        string                    `yaml:"profile,omitempty"`
+	Profiles       map[string]configSettings `yaml:"profiles,omitempty"`
+}
+
+// setting describes one setting: the flag it is the default of, its
+// environment variable and its built in default.  A setting with several
+// values, like hooks, has one per line.
+type setting struct {
+	name   string
+	env    string
+	def    string
+	config func(s configSettings) string
+	check  func(value string) error
+}
+
+var settings = []setting{
+	{"db", "TODO_DB", "./data/todo.json", func(s configSettings) string { return s.DB }, nil},
+	{"list", "TODO_LIST", db.DefaultList, func(s configSettings) string { return s.List }, func(v string) error {
+		_, err := db.ParseListName(v)
+		return err
+	}},
+	{"output", "TODO_OUTPUT", render.Auto, func(s configSettings) string { return s.Output }, func(v string) error {
+		_, err := render.New(v, os.Stdout)
+		return err
+	}},
+	{"sort", "TODO_SORT", string(db.SortById), func(s configSettings) string { return s.Sort }, func(v string) error {
+		_, err := db.ParseSortKey(v)
+		return err
+	}},
+	{"hooks", "TODO_HOOKS", "", func(s configSettings) string { return strings.Join(s.Hooks, "\n") }, func(v string) error {
+		_, err := parseHooks(strings.Split(v, "\n"))
+		return err
+	}},
+}
+
+// resolvedSetting is the value a setting ends up with and where it came
+// from
+type resolvedSetting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// config is what was worked out from the config file and environment
+type config struct {
+	// path is the config file, loaded is set if it exists
+	path   string
+	loaded bool
+
+	profile       string
+	profileSource string
+
+	file configFile
+}
+
+// activeConfig is the config of the running command, loaded before it
+// runs
+var activeConfig *config
+
+// configPath is the config file to use, and whether it was named by
+// TODO_CONFIG rather than found in the default place
+func configPath() (string, bool, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, true, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, "todo", "config.yaml"), false, nil
+}
+
+// loadConfig reads the config file, a missing file is an empty config
+// unless TODO_CONFIG named it, and picks the profile
+func loadConfig(profile string, profileSet bool) (*config, error) {
+	path, named, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	conf := &config{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		conf.loaded = true
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&conf.file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("reading config file %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !named:
+	default:
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	//The profile is picked like any other setting
+	switch {
+	case profileSet:
+		conf.profile, conf.profileSource = profile, "flag"
+	case os.Getenv(profileEnv) != "":
+		conf.profile, conf.profileSource = os.Getenv(profileEnv), "env "+profileEnv
+	case conf.file.Profile != "":
+		conf.profile, conf.profileSource = conf.file.Profile, "config"
+	}
+	if conf.profile != "" {
+		if _, ok := conf.file.Profiles[conf.profile]; !ok {
+			return nil, fmt.Errorf("no profile %q in config file %s", conf.profile, path)
+		}
+	}
+
+	return conf, nil
+}
+
+// resolve works out the value of a setting that was not given as a flag
+func (conf *config) resolve(s setting) (resolvedSetting, error) {
+	r := resolvedSetting{Name: s.name, Value: s.def, Source: "default"}
+
+	if value := os.Getenv(s.env); value != "" {
+		r.Value, r.Source = value, "env "+s.env
+	} else if value := s.config(conf.file.Profiles[conf.profile]); value != "" {
+		r.Value, r.Source = conf.expand(s, value), "config profile "+conf.profile
+	} else if value := s.config(conf.file.configSettings); value != "" {
+		r.Value, r.Source = conf.expand(s, value), "config"
+	}
+
+	if s.check != nil && r.Source != "default" {
+		if err := s.check(r.Value); err != nil {
+			return r, fmt.Errorf("%s from %s: %w", s.name, r.Source, err)
+		}
+	}
+	return r, nil
+}
+
+// expand makes a database path from the config file independent of the
+// directory todo is run from: ~ is the home directory and a relative
+// path is relative to the config file
+func (conf *config) expand(s setting, value string) string {
+	if s.name != "db" {
+		return value
+	}
+
+	prefix := ""
+	if scheme, path, ok := strings.Cut(value, "://"); ok {
+		prefix, value = scheme+"://", path
+	}
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			value = filepath.Join(home, value[1:])
+		}
+	}
+	if !filepath.IsAbs(value) {
+		value = filepath.Join(filepath.Dir(conf.path), value)
+	}
+
+	return prefix + value
+}
+
+// fromConfig marks a flag as taking its default from a setting
+func fromConfig(flags *pflag.FlagSet, flag, setting string) {
+	if err := flags.SetAnnotation(flag, configAnnotation, []string{setting}); err != nil {
+		panic(err)
+	}
+}
+
+// applyConfig loads the config and sets every flag of c that takes its
+// default from a setting and was not given on the command line
+func applyConfig(c *cobra.Command) error {
+	conf, err := loadConfig(profileFlag, c.Flags().Changed("profile"))
+	if err != nil {
+		return err
+	}
+	activeConfig = conf
+
+	byName := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byName[s.name] = s
+	}
+
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		names := f.Annotations[configAnnotation]
+		if err != nil || f.Changed || len(names) == 0 {
+			return
+		}
+
+		var r resolvedSetting
+		if r, err = conf.resolve(byName[names[0]]); err != nil || r.Source == "default" {
+			return
+		}
+		//A repeatable flag gets every value of the setting
+		if list, ok := f.Value.(pflag.SliceValue); ok {
+			err = list.Replace(strings.Split(r.Value, "\n"))
+		} else {
+			err = f.Value.Set(r.Value)
+		}
+	})
+
+	return err
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show where the settings of todo come from",
+	Long: `todo reads its defaults from a YAML config file, found at TODO_CONFIG or
+else at todo/config.yaml in the user config directory ($XDG_CONFIG_HOME
+or ~/.config on Linux):
+
+  db: ~/todo/todo.json        # the database, relative to the config file
+  list: default               # the list add and import put items on
+  output: table               # the output of ls, get and the like
+  sort: id                    # the sort order of ls
+  profile: work               # the profile used when none is picked
+  hooks:                      # the --hook commands
+    - done=./post-to-chat.sh
+  profiles:
+    work:
+      db: ~/work/todo.json
+      list: work
+
+A profile overrides the settings at the top, pick one with --profile or
+TODO_PROFILE.  Every setting can also be set with its environment
+variable: TODO_DB, TODO_LIST, TODO_OUTPUT, TODO_SORT and TODO_HOOKS (one
+hook per line).  A flag beats the environment, which beats the config
+file, which beats the default, and --hook flags replace the hooks of
+the config file rather than adding to them.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the settings in effect and where each comes from",
+	Example: `  todo config show
+  todo --profile work config show`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		conf := activeConfig
+
+		state := "not found"
+		if conf.loaded {
+			state = "loaded"
+		}
+		fmt.Printf("%-10s %s (%s)\n", "config", conf.path, state)
+		if conf.profile != "" {
+			fmt.Printf("%-10s %s (from %s)\n", "profile", conf.profile, conf.profileSource)
+		}
+		if len(conf.file.Profiles) > 0 {
+			names := make([]string, 0, len(conf.file.Profiles))
+			for name := range conf.file.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("%-10s %s\n", "profiles", strings.Join(names, ", "))
+		}
+		fmt.Println()
+
+		for _, s := range settings {
+			r, err := conf.resolve(s)
+			if err != nil {
+				return err
+			}
+			//Only --db is a flag of this command too
+			if s.name == "db" && c.Flags().Changed("db") {
+				r.Value, r.Source = dbFileNameFlag, "flag"
+			}
+			//Settings with several values get a line each
+			values := strings.Split(r.Value, "\n")
+			fmt.Printf("%-10s %-30s (%s)\n", r.Name, values[0], r.Source)
+			for _, value := range values[1:] {
+				fmt.Printf("%-10s %s\n", "", value)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
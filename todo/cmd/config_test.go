@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// writeConfig writes a config file and points TODO_CONFIG at it, with
+// none of the settings coming from the environment of the test run
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configEnv, path)
+	for _, env := range []string{profileEnv, "TODO_DB", "TODO_LIST", "TODO_OUTPUT", "TODO_SORT", "TODO_HOOKS"} {
+		t.Setenv(env, "")
+	}
+	return path
+}
+
+// configTestCmd is a command with the db, list and hook flags set from
+// the config, after parsing args
+func configTestCmd(t *testing.T, args ...string) (db, list *string, hooks *[]string, err error) {
+	t.Helper()
+
+	c := &cobra.Command{Use: "test"}
+	db, list, hooks = new(string), new(string), new([]string)
+	c.Flags().StringVar(db, "db", "./data/todo.json", "")
+	fromConfig(c.Flags(), "db", "db")
+	c.Flags().StringVar(list, "list", "default", "")
+	fromConfig(c.Flags(), "list", "list")
+	c.Flags().StringArrayVar(hooks, "hook", nil, "")
+	fromConfig(c.Flags(), "hook", "hooks")
+	c.Flags().StringVar(&profileFlag, "profile", "", "")
+	t.Cleanup(func() { profileFlag = "" })
+
+	if err := c.ParseFlags(args); err != nil {
+		t.Fatal(err)
+	}
+	return db, list, hooks, applyConfig(c)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	dir := filepath.Dir(writeConfig(t, `
+db: top.json
+list: home
+hooks:
+  - add=echo top
+profiles:
+  work:
+    db: /work/todo.json
+    hooks:
+      - done=echo work
+`))
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		db    string
+		list  string
+		hooks []string
+	}{
+		{"config", nil, nil, filepath.Join(dir, "top.json"), "home", []string{"add=echo top"}},
+		{"profile", nil, []string{"--profile", "work"}, "/work/todo.json", "home", []string{"done=echo work"}},
+		{"profile from env", map[string]string{profileEnv: "work"}, nil, "/work/todo.json", "home", []string{"done=echo work"}},
+		{"env", map[string]string{"TODO_DB": "env.json", "TODO_HOOKS": "add=echo a\ndelete=echo b"}, []string{"--profile", "work"},
+			"env.json", "home", []string{"add=echo a", "delete=echo b"}},
+		{"flag", map[string]string{"TODO_DB": "env.json", "TODO_LIST": "env"}, []string{"--db", "flag.json", "--list", "flag", "--hook", "echo flag"},
+			"flag.json", "flag", []string{"echo flag"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			db, list, hooks, err := configTestCmd(t, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *db != tt.db || *list != tt.list || !reflect.DeepEqual(*hooks, tt.hooks) {
+				t.Errorf("got db %q, list %q, hooks %q, want %q, %q, %q", *db, *list, *hooks, tt.db, tt.list, tt.hooks)
+			}
+		})
+	}
+}
+
+func TestConfigDefault(t *testing.T) {
+	writeConfig(t, "")
+	db, list, hooks, err := configTestCmd(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *db != "./data/todo.json" || *list != "default" || *hooks != nil {
+		t.Errorf("got db %q, list %q, hooks %q, want the defaults", *db, *list, *hooks)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+	}{
+		{"malformed", "db: [", nil},
+		{"unknown setting", "colour: red", nil},
+		{"bad hook", "hooks:\n  - never=echo", nil},
+		{"unknown profile", "db: x.json", []string{"--profile", "nope"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfig(t, tt.config)
+			if _, _, _, err := configTestCmd(t, tt.args...); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		path := writeConfig(t, "")
+		os.Remove(path)
+		if _, _, _, err := configTestCmd(t); err == nil {
+			t.Error("got no error for a TODO_CONFIG that does not exist")
+		}
+	})
+}
+
+// A broken config file is not a usage error
+func TestConfigErrorExitCode(t *testing.T) {
+	writeConfig(t, "db: [")
+	rootCmd.SetArgs([]string{"config", "show"})
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+
+	if code := Execute(); code != ExitDB {
+		t.Errorf("got exit code %d, want %d", code, ExitDB)
+	}
+}
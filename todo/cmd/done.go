@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var doneForceFlag bool
+
+var doneCmd = &cobra.Command{
+	Use:   "done <id>...",
+	Short: "Mark one or more items as done",
+	Long: `Mark one or more items as done.  An item is not marked done while items
+blocking it or its subtasks are still open, unless --force is given.`,
+	Example: `  todo done 3
+  todo done --force 5`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		return setDoneStatus(args, true)
+	},
+}
+
+var undoneCmd = &cobra.Command{
+	Use:     "undone <id>...",
+	Short:   "Mark one or more items as not done",
+	Example: `  todo undone 3`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		return setDoneStatus(args, false)
+	},
+}
+
+func init() {
+	doneCmd.Flags().BoolVarP(&doneForceFlag, "force", "f", false, "Mark items done even if they are blocked or have open subtasks")
+	rootCmd.AddCommand(doneCmd)
+	rootCmd.AddCommand(undoneCmd)
+}
+
+func setDoneStatus(args []string, value bool) error {
+	ids, err := parseIds(args)
+	if err != nil {
+		return err
+	}
+
+	return withDB(func(todo *db.ToDo) error {
+		for _, id := range ids {
+			var err error
+			if value && doneForceFlag {
+				err = todo.ForceItemDone(id)
+			} else {
+				err = todo.ChangeItemDoneStatus(id, value)
+			}
+			if err != nil {
+				return fmt.Errorf("item %d: %w", id, err)
+			}
+			fmt.Printf("Updated item %d done status to %t\n", id, value)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import "golang.org/x/sys/unix"
+
+// disableEcho turns off echoing of what is typed on the terminal fd and
+// returns a function that turns it back on
+func disableEcho(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+
+	old := *termios
+	termios.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+
+	return func() { unix.IoctlSetTermios(fd, unix.TCSETS, &old) }, nil
+}
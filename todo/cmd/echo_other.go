@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package cmd
+
+import "errors"
+
+// disableEcho is not supported here, the passphrase is typed in the
+// open.  Set TODO_PASSPHRASE to avoid that.
+func disableEcho(fd int) (func(), error) {
+	return nil, errors.New("cannot turn off echo on this platform")
+}
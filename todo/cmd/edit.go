@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	editTitleFlag string
+	editDoneFlag  bool
+	editItemFlags itemFlags
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Change an item in the database",
+	Long: `Change an existing item.  Only the fields given as flags are changed,
+everything else about the item is left as it was.  Use --due "" to
+remove a due date, --tag "" to remove all tags, --parent 0 to make a
+subtask a top level item again, --blocked-by "" to unblock it and
+--repeat none to stop it repeating.`,
+	Example: `  todo edit 2 --title "Learn Kubernetes properly"
+  todo edit 2 --done=false
+  todo edit 2 --due tomorrow --priority medium --tag work,cloud
+  todo edit 5 --parent 2 --blocked-by 3,4`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+
+		flags := c.Flags()
+		if !flags.Changed("title") && !flags.Changed("done") && !editItemFlags.changed(c) {
+			return errors.New("nothing to change, use --title, --done, --due, --priority, --tag, --notes, --parent, --blocked-by, --repeat or --until")
+		}
+		if err := editItemFlags.parse(c); err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			item, err := todo.GetItem(ids[0])
+			if err != nil {
+				return err
+			}
+
+			if flags.Changed("title") {
+				item.Title = editTitleFlag
+			}
+			if flags.Changed("done") {
+				item.IsDone = editDoneFlag
+			}
+			if err := editItemFlags.apply(c, &item); err != nil {
+				return err
+			}
+
+			if err := todo.UpdateItem(item); err != nil {
+				return err
+			}
+
+			fmt.Println("Updated item", item.Id)
+			return nil
+		})
+	},
+}
+
+func init() {
+	editCmd.Flags().StringVar(&editTitleFlag, "title", "", "New title for the item")
+	editCmd.Flags().BoolVar(&editDoneFlag, "done", false, "New done status for the item")
+	editItemFlags.register(editCmd)
+	rootCmd.AddCommand(editCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the database with a passphrase",
+	Long: `Encrypt the database file, and its journal, with a key derived from a
+passphrase.  From then on every command needs the passphrase, which is
+read from TODO_PASSPHRASE or asked for on the terminal.
+
+The new passphrase is read from TODO_NEW_PASSPHRASE, or asked for twice.
+There is no way to recover the items if the passphrase is lost.  Only the
+JSON file backend can be encrypted.`,
+	Example: `  todo encrypt
+  TODO_NEW_PASSPHRASE=secret todo encrypt`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			encrypted, err := todo.IsEncrypted()
+			if err != nil {
+				return err
+			}
+			if encrypted {
+				return errors.New("the database is already encrypted, use todo rekey to change the passphrase")
+			}
+
+			passphrase, err := newPassphrase()
+			if err != nil {
+				return err
+			}
+			if err := todo.Encrypt(passphrase); err != nil {
+				return err
+			}
+
+			fmt.Println("Encrypted", dbFileNameFlag)
+			return nil
+		})
+	},
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Turn an encrypted database back into plain text",
+	Long: `Decrypt the database file, and its journal, so the passphrase is no
+longer needed.`,
+	Example: `  todo decrypt`,
+	Args:    cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			if err := todo.Decrypt(); err != nil {
+				return err
+			}
+
+			fmt.Println("Decrypted", dbFileNameFlag)
+			return nil
+		})
+	},
+}
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the passphrase of an encrypted database",
+	Long: `Encrypt the database file, and its journal, again under a new
+passphrase.  The current passphrase is read from TODO_PASSPHRASE and the
+new one from TODO_NEW_PASSPHRASE, either is asked for when not set.`,
+	Example: `  todo rekey`,
+	Args:    cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			//Open the database with the current passphrase before asking
+			//for the new one
+			encrypted, err := todo.IsEncrypted()
+			if err != nil {
+				return err
+			}
+			if !encrypted {
+				return errors.New("the database is not encrypted, use todo encrypt to encrypt it")
+			}
+
+			passphrase, err := newPassphrase()
+			if err != nil {
+				return err
+			}
+			if err := todo.Rekey(passphrase); err != nil {
+				return err
+			}
+
+			fmt.Println("Changed the passphrase of", dbFileNameFlag)
+			return nil
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(rekeyCmd)
+}
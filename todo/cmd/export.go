@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/exchange"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportListFlag   string
+	exportFormatFlag string
+	exportOutputFlag string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write every item out in another format",
+	Long: `Write every item in the database out as JSON, CSV, a Markdown checklist
+or todo.txt.  Without --format the format is taken from the extension of
+the --output file, and is JSON when writing to standard output.  Use
+--list to only export the items on one list.`,
+	Example: `  todo export --format md
+  todo export -o backup.csv
+  todo export --format todotxt -o todo.txt`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		format := exchange.JSON
+		if exportFormatFlag != "" {
+			var err error
+			if format, err = exchange.ParseFormat(exportFormatFlag); err != nil {
+				return err
+			}
+		} else if f, ok := exchange.FormatForFile(exportOutputFlag); ok {
+			format = f
+		}
+
+		var q db.Query
+		if exportListFlag != "" {
+			var err error
+			if q.List, err = db.ParseListName(exportListFlag); err != nil {
+				return err
+			}
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			items, err := todo.Find(q)
+			if err != nil {
+				return err
+			}
+
+			var out bytes.Buffer
+			if err := exchange.Export(&out, format, items); err != nil {
+				return err
+			}
+
+			if exportOutputFlag == "" || exportOutputFlag == "-" {
+				_, err = os.Stdout.Write(out.Bytes())
+				return err
+			}
+			if err := os.WriteFile(exportOutputFlag, out.Bytes(), 0644); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Exported %d items to %s\n", len(items), exportOutputFlag)
+			return nil
+		})
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormatFlag, "format", "f", "", "Format to write: json, csv, md or todotxt")
+	exportCmd.Flags().StringVarP(&exportListFlag, "list", "l", "", "Only export the items on this list (default all lists)")
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "", "Write to this file instead of standard output")
+	rootCmd.AddCommand(exportCmd)
+}
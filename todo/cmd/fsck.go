@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var fsckRepairFlag bool
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the database for damage and optionally repair it",
+	Long: `Check that the database can be read and that its items follow the rules
+the rest of todo keeps: unique ids, a title and a valid list, links that
+point at items that exist and do not form cycles, and timestamps that
+match the done status.  Each problem is reported with where it was found
+in the file.
+
+With --repair the problems are fixed: items that cannot be read are
+dropped (whatever can still be read out of a damaged file is kept),
+duplicate ids are renumbered, broken links removed and missing fields
+filled in.  The damaged file is copied to <db>.<time>.bak first.`,
+	Example: `  todo fsck
+  todo fsck --repair`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			var report db.FsckReport
+			var err error
+			if fsckRepairFlag {
+				report, err = todo.Repair()
+			} else {
+				report, err = todo.Check()
+			}
+
+			fmt.Printf("Checked %s: %s, %s\n", dbFileNameFlag, plural(report.Items, "item"), plural(len(report.Problems), "problem"))
+			for _, p := range report.Problems {
+				if p.Fix == "" {
+					fmt.Printf("  %s (cannot be repaired)\n", p)
+				} else {
+					fmt.Printf("  %s (repair: %s)\n", p, p.Fix)
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case report.Repaired:
+				fmt.Printf("Repaired the database, the damaged file was saved as %s\n", report.Backup)
+			case len(report.Problems) > 0 && report.Fixable():
+				return fmt.Errorf("found %s, run todo fsck --repair to fix them", plural(len(report.Problems), "problem"))
+			case len(report.Problems) > 0:
+				return fmt.Errorf("found %s, some of which cannot be repaired", plural(len(report.Problems), "problem"))
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	fsckCmd.Flags().BoolVar(&fsckRepairFlag, "repair", false, "Fix the problems found, after backing up the database")
+	rootCmd.AddCommand(fsckCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var getOutputFlag string
+
+var getCmd = &cobra.Command{
+	Use:     "get <id>...",
+	Aliases: []string{"show"},
+	Short:   "Show one or more items from the database",
+	Example: `  todo get 3
+  todo get 3 4 --output yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+		renderer, err := newRenderer(getOutputFlag)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			items := make([]db.ToDoItem, 0, len(ids))
+			for _, id := range ids {
+				item, err := todo.GetItem(id)
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+			}
+
+			//A single item is shown on its own, several as a listing
+			if len(items) == 1 {
+				return renderer.RenderItem(os.Stdout, items[0])
+			}
+			return renderer.RenderItems(os.Stdout, items)
+		})
+	},
+}
+
+func init() {
+	addOutputFlag(getCmd, &getOutputFlag)
+	rootCmd.AddCommand(getCmd)
+}
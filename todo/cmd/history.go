@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var historyLimitFlag int
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the most recent change to the database",
+	Long: `Revert the most recent change to the database that has not been undone
+yet.  Run it again to keep going back through the history.`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			entry, err := todo.Undo()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Undid #%d %s\n", entry.Seq, entry)
+			return nil
+		})
+	},
+}
+
+var redoCmd = &cobra.Command{
+	Use:   "redo",
+	Short: "Re-apply the change most recently undone",
+	Args:  cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			entry, err := todo.Redo()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Redid #%d %s\n", entry.Seq, entry)
+			return nil
+		})
+	},
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the journal of changes made to the database",
+	Long: `Show the journal of changes made to the database, newest first.  Changes
+that are currently undone are marked, they can be brought back with redo.`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			history, err := todo.History()
+			if err != nil {
+				return err
+			}
+
+			if historyLimitFlag > 0 && historyLimitFlag < len(history) {
+				history = history[:historyLimitFlag]
+			}
+
+			for _, entry := range history {
+				undone := ""
+				if entry.Undone {
+					undone = " (undone)"
+				}
+				fmt.Printf("%5d  %s  %s%s\n", entry.Seq, entry.Time.Local().Format("2006-01-02 15:04"), entry, undone)
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimitFlag, "limit", "n", 20, "Show at most this many entries (0 for all)")
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(redoCmd)
+	rootCmd.AddCommand(historyCmd)
+}
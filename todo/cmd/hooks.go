@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+// hookFlag holds the --hook flags shared by every subcommand, as given
+var hookFlag []string
+
+// shellHook is a --hook flag once it has been checked
+type shellHook struct {
+	events  []db.HookEvent
+	command string
+}
+
+// shellHooks are the checked --hook flags, withDB registers them
+var shellHooks []shellHook
+
+// parseHooks checks the --hook flags, each of the form event=command
+// where event is one of the db.HookEvents, several joined with commas,
+// or * for all of them
+func parseHooks(flags []string) ([]shellHook, error) {
+	hooks := make([]shellHook, 0, len(flags))
+	for _, flag := range flags {
+		names, command, ok := strings.Cut(flag, "=")
+		command = strings.TrimSpace(command)
+		if !ok || command == "" {
+			return nil, fmt.Errorf("invalid --hook %q, use event=command", flag)
+		}
+
+		var h shellHook
+		h.command = command
+		if strings.TrimSpace(names) != "*" {
+			for _, name := range strings.Split(names, ",") {
+				event, err := db.ParseHookEvent(strings.TrimSpace(name))
+				if err != nil {
+					return nil, err
+				}
+				h.events = append(h.events, event)
+			}
+		}
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+// addShellHooks registers the --hook commands with the database
+func addShellHooks(todo *db.ToDo, hooks []shellHook) {
+	for _, h := range hooks {
+		todo.AddHook(db.ShellHook(h.command), h.events...)
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/exchange"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importListFlag      string
+	importFormatFlag    string
+	importDuplicateFlag string
+	importDryRunFlag    bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Add the items from a JSON, CSV, Markdown or todo.txt file",
+	Long: `Add the items from a file written by export or by another tool.  The
+format is worked out from the file extension or, failing that, from its
+content, use --format to override it.  Use - to read standard input.
+
+Imported items keep their ids when they are free.  --on-duplicate says
+what to do with an item whose id is already in use: skip it, overwrite
+the existing item, renumber it and add it as a new item, or fail and
+import nothing.  Items
+without an id are always added as new items.  Items the file does not
+put on a list go on the --list list.
+
+The whole import is a single change, one undo reverts all of it.  Use
+--dry-run to see what would happen without changing anything.`,
+	Example: `  todo import backup.json
+  todo import --dry-run --on-duplicate overwrite todo.txt
+  cat list.md | todo import --format md -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		mode, err := db.ParseDuplicateMode(importDuplicateFlag)
+		if err != nil {
+			return err
+		}
+		list, err := db.ParseListName(importListFlag)
+		if err != nil {
+			return err
+		}
+		var format exchange.Format
+		if importFormatFlag != "" {
+			if format, err = exchange.ParseFormat(importFormatFlag); err != nil {
+				return err
+			}
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			data, err := readImportFile(args[0])
+			if err != nil {
+				return err
+			}
+			if format == "" {
+				format = exchange.DetectFormat(args[0], data)
+			}
+
+			items, err := exchange.Import(data, format)
+			if err != nil {
+				return fmt.Errorf("reading %s as %s: %w", args[0], format, err)
+			}
+			for i := range items {
+				if items[i].List == "" {
+					items[i].List = list
+				}
+			}
+
+			report, err := todo.ImportItems(items, mode, importDryRunFlag)
+			if err != nil {
+				return err
+			}
+
+			printImportReport(report)
+			return nil
+		})
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVarP(&importListFlag, "list", "l", db.DefaultList, "List for items the file does not put on one")
+	fromConfig(importCmd.Flags(), "list", "list")
+	importCmd.Flags().StringVarP(&importFormatFlag, "format", "f", "", "Format of the file: json, csv, md or todotxt (default detected)")
+	importCmd.Flags().StringVar(&importDuplicateFlag, "on-duplicate", string(db.DuplicateSkip), "What to do with items whose id is taken: skip, overwrite, renumber or fail")
+	importCmd.Flags().BoolVarP(&importDryRunFlag, "dry-run", "n", false, "Report what would be imported without changing the database")
+	rootCmd.AddCommand(importCmd)
+}
+
+func readImportFile(fileName string) ([]byte, error) {
+	if fileName == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+
+	return os.ReadFile(fileName)
+}
+
+func printImportReport(report db.ImportReport) {
+	verb := "Imported"
+	if report.DryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d items: %d added, %d renumbered, %d overwritten, %d skipped\n",
+		verb, len(report.Added)+len(report.Renumbered)+len(report.Overwritten),
+		len(report.Added), len(report.Renumbered), len(report.Overwritten), len(report.Skipped))
+
+	printIdList("added", report.Added)
+
+	if len(report.Renumbered) > 0 {
+		moves := make([]string, len(report.Renumbered))
+		for i, r := range report.Renumbered {
+			if r.From == 0 {
+				moves[i] = fmt.Sprintf("new -> %d", r.To)
+			} else {
+				moves[i] = fmt.Sprintf("%d -> %d", r.From, r.To)
+			}
+		}
+		fmt.Printf("  %-12s %s\n", "renumbered", strings.Join(moves, ", "))
+	}
+
+	printIdList("overwritten", report.Overwritten)
+	printIdList("skipped", report.Skipped)
+}
+
+func printIdList(label string, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = fmt.Sprint(id)
+	}
+	fmt.Printf("  %-12s %s\n", label, strings.Join(list, ", "))
+}
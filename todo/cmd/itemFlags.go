@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+// itemFlags are the flags shared by the commands that fill in the fields
+// of an item (add and edit)
+type itemFlags struct {
+	due       string
+	priority  string
+	tags      []string
+	notes     string
+	parent    int
+	blockedBy []string
+	repeat    string
+	until     string
+
+	// filled in by parse
+	dueDate      *time.Time
+	itemPriority db.Priority
+	blockers     []int
+	recur        *db.Recurrence
+	untilDate    *time.Time
+}
+
+func (f *itemFlags) register(c *cobra.Command) {
+	c.Flags().StringVar(&f.due, "due", "", "Due date (YYYY-MM-DD, YYYY-MM-DD HH:MM, today or tomorrow)")
+	c.Flags().StringVarP(&f.priority, "priority", "p", "", "Priority: none, low, medium or high")
+	c.Flags().StringSliceVarP(&f.tags, "tag", "t", nil, "Tag for the item (repeat or comma separate for several)")
+	c.Flags().StringVar(&f.notes, "notes", "", "Free form notes")
+	c.Flags().IntVar(&f.parent, "parent", 0, "Make the item a subtask of this item (0 for none)")
+	c.Flags().StringSliceVar(&f.blockedBy, "blocked-by", nil, "Ids of items that must be done before this one")
+	c.Flags().StringVar(&f.repeat, "repeat", "", "Repeat the item: daily, weekly, monthly, \"every N days\" (or weeks, months) or none")
+	c.Flags().StringVar(&f.until, "until", "", "Last date a repeating item may be due")
+}
+
+// parse checks the values given to the flags, so mistakes are reported
+// as usage errors before the database is touched
+func (f *itemFlags) parse(c *cobra.Command) error {
+	var err error
+
+	if c.Flags().Changed("due") {
+		if f.dueDate, err = db.ParseDate(f.due); err != nil {
+			return err
+		}
+	}
+	if c.Flags().Changed("priority") {
+		if f.itemPriority, err = db.ParsePriority(f.priority); err != nil {
+			return err
+		}
+	}
+	if c.Flags().Changed("blocked-by") {
+		var blockedBy []string
+		for _, id := range f.blockedBy {
+			if id = strings.TrimSpace(id); id != "" {
+				blockedBy = append(blockedBy, id)
+			}
+		}
+		if f.blockers, err = parseIds(blockedBy); err != nil {
+			return err
+		}
+	}
+	if c.Flags().Changed("repeat") {
+		if f.recur, err = db.ParseRecurrence(f.repeat); err != nil {
+			return err
+		}
+	}
+	if c.Flags().Changed("until") {
+		if f.untilDate, err = db.ParseDate(f.until); err != nil {
+			return err
+		}
+	}
+	if f.parent < 0 {
+		return errors.New("--parent must be an item id, or 0 for none")
+	}
+
+	return nil
+}
+
+// apply copies the flags the user actually set onto item, so an edit
+// leaves every other field alone.  An empty --due clears the due date,
+// --parent 0 and an empty --blocked-by clear the links.  --until changes
+// the end date of the item's repeat rule, so the item must have one.
+func (f *itemFlags) apply(c *cobra.Command, item *db.ToDoItem) error {
+	flags := c.Flags()
+
+	if flags.Changed("due") {
+		item.Due = f.dueDate
+	}
+	if flags.Changed("priority") {
+		item.Priority = f.itemPriority
+	}
+	if flags.Changed("tag") {
+		item.Tags = nil
+		for _, tag := range f.tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	}
+	if flags.Changed("notes") {
+		item.Notes = f.notes
+	}
+	if flags.Changed("parent") {
+		item.ParentId = f.parent
+	}
+	if flags.Changed("blocked-by") {
+		item.BlockedBy = f.blockers
+	}
+	if flags.Changed("repeat") {
+		item.Recur = f.recur
+	}
+	if flags.Changed("until") {
+		if item.Recur == nil {
+			return errors.New("--until needs a repeating item, use --repeat as well")
+		}
+		recur := *item.Recur
+		recur.Until = f.untilDate
+		item.Recur = &recur
+	}
+
+	return nil
+}
+
+// changed reports whether any of the item flags were set
+func (f *itemFlags) changed(c *cobra.Command) bool {
+	for _, name := range []string{"due", "priority", "tag", "notes", "parent", "blocked-by", "repeat", "until"} {
+		if c.Flags().Changed(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <id>... <list>",
+	Short: "Move items to another list",
+	Long: `Move one or more items to another list, creating the list if it does
+not have any items yet.  Subtasks move along with their parent.`,
+	Example: `  todo mv 3 work
+  todo mv 4 7 home`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+		list, err := db.ParseListName(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			moved, err := todo.MoveItems(ids, list)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range moved {
+				fmt.Printf("Moved item %d to %s\n", id, list)
+			}
+			return nil
+		})
+	},
+}
+
+var listsCmd = &cobra.Command{
+	Use:   "lists",
+	Short: "Show the lists in the database with counts of their items",
+	Args:  cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		return withDB(func(todo *db.ToDo) error {
+			lists, err := todo.Lists()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%-20s %6s %6s %8s %6s\n", "LIST", "OPEN", "DONE", "OVERDUE", "TOTAL")
+			for _, list := range lists {
+				fmt.Printf("%-20s %6d %6d %8d %6d\n", list.Name, list.Open, list.Done, list.Overdue, list.Total)
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+	rootCmd.AddCommand(listsCmd)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lsListFlag      string
+	lsPendingFlag   bool
+	lsDoneFlag      bool
+	lsTagFlag       []string
+	lsPriorityFlag  []string
+	lsDueBeforeFlag string
+	lsDueAfterFlag  string
+	lsSearchFlag    string
+	lsRegexFlag     string
+	lsSortFlag      string
+	lsReverseFlag   bool
+	lsLimitFlag     int
+	lsOffsetFlag    int
+	lsTreeFlag      bool
+	lsArchivedFlag  bool
+	lsOutputFlag    string
+)
+
+var lsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List the items in the database",
+	Long: `List the items in the database.  The filter flags can be combined, an
+item is only listed if it matches all of them.  Archived items are only
+listed with --archived, which lists them instead of the live ones.`,
+	Example: `  todo ls
+  todo ls --pending --tag work --sort due
+  todo ls --list home
+  todo ls --priority high,medium --due-before 2023-10-01
+  todo ls --search milk
+  todo ls --sort updated --reverse --limit 5
+  todo ls --tree --pending
+  todo ls --archived
+  todo ls --output plain
+  todo ls -o template='{{.Id}} {{.Title}}'`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		q, err := lsQuery()
+		if err != nil {
+			return err
+		}
+		if lsTreeFlag && c.Flags().Changed("output") {
+			return errors.New("--tree and --output cannot be used together")
+		}
+		renderer, err := newRenderer(lsOutputFlag)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			items, err := todo.Find(q)
+			if err != nil {
+				return err
+			}
+
+			if lsTreeFlag {
+				printTree(items)
+				return nil
+			}
+
+			return renderer.RenderItems(os.Stdout, items)
+		})
+	},
+}
+
+func init() {
+	flags := lsCmd.Flags()
+	flags.StringVarP(&lsListFlag, "list", "l", "", "Only list items on this list (default all lists)")
+	flags.BoolVar(&lsPendingFlag, "pending", false, "Only list items that are not done")
+	flags.BoolVar(&lsDoneFlag, "done", false, "Only list items that are done")
+	flags.StringSliceVarP(&lsTagFlag, "tag", "t", nil, "Only list items with this tag (repeat to require several)")
+	flags.StringSliceVarP(&lsPriorityFlag, "priority", "p", nil, "Only list items with one of these priorities")
+	flags.StringVar(&lsDueBeforeFlag, "due-before", "", "Only list items due before this date")
+	flags.StringVar(&lsDueAfterFlag, "due-after", "", "Only list items due after this date")
+	flags.StringVarP(&lsSearchFlag, "search", "s", "", "Only list items whose title contains this text")
+	flags.StringVar(&lsRegexFlag, "regex", "", "Only list items whose title matches this regular expression")
+	flags.StringVar(&lsSortFlag, "sort", string(db.SortById), "Sort by id, due, priority, title, created or updated")
+	fromConfig(flags, "sort", "sort")
+	flags.BoolVarP(&lsReverseFlag, "reverse", "r", false, "Reverse the sort order")
+	flags.IntVar(&lsLimitFlag, "limit", 0, "List at most this many items")
+	flags.IntVar(&lsOffsetFlag, "offset", 0, "Skip this many items before listing")
+	flags.BoolVar(&lsTreeFlag, "tree", false, "Show the items as a tree of subtasks, one line each")
+	flags.BoolVar(&lsArchivedFlag, "archived", false, "List the archived items instead of the live ones")
+	addOutputFlag(lsCmd, &lsOutputFlag)
+	rootCmd.AddCommand(lsCmd)
+}
+
+// lsQuery turns the ls flags into a database query
+func lsQuery() (db.Query, error) {
+	var q db.Query
+
+	if lsListFlag != "" {
+		list, err := db.ParseListName(lsListFlag)
+		if err != nil {
+			return q, err
+		}
+		q.List = list
+	}
+
+	if lsPendingFlag && lsDoneFlag {
+		return q, errors.New("--pending and --done cannot be used together")
+	}
+	if lsPendingFlag || lsDoneFlag {
+		done := lsDoneFlag
+		q.Done = &done
+	}
+
+	archived := lsArchivedFlag
+	q.Archived = &archived
+
+	q.Tags = lsTagFlag
+
+	for _, p := range lsPriorityFlag {
+		priority, err := db.ParsePriority(p)
+		if err != nil {
+			return q, err
+		}
+		q.Priorities = append(q.Priorities, priority)
+	}
+
+	var err error
+	if q.DueBefore, err = db.ParseDate(lsDueBeforeFlag); err != nil {
+		return q, err
+	}
+	if q.DueAfter, err = db.ParseDate(lsDueAfterFlag); err != nil {
+		return q, err
+	}
+
+	q.Title = lsSearchFlag
+	if lsRegexFlag != "" {
+		if q.TitleRegex, err = regexp.Compile(lsRegexFlag); err != nil {
+			return q, err
+		}
+	}
+
+	if q.SortBy, err = db.ParseSortKey(lsSortFlag); err != nil {
+		return q, err
+	}
+	q.Reverse = lsReverseFlag
+
+	if lsLimitFlag < 0 || lsOffsetFlag < 0 {
+		return q, errors.New("--limit and --offset cannot be negative")
+	}
+	q.Limit = lsLimitFlag
+	q.Offset = lsOffsetFlag
+
+	return q, nil
+}
+
+// printTree prints one line per item with subtasks indented under their
+// parent, keeping the order of the listing.  A subtask whose parent was
+// filtered out of the listing is shown at the top level.  When the items
+// come from more than one list they are grouped under the list name.
+func printTree(items []db.ToDoItem) {
+	listed := make(map[int]bool, len(items))
+	for _, item := range items {
+		listed[item.Id] = true
+	}
+
+	children := make(map[int][]db.ToDoItem)
+	var roots []db.ToDoItem
+	for _, item := range items {
+		if item.ParentId != 0 && listed[item.ParentId] {
+			children[item.ParentId] = append(children[item.ParentId], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	var printItems func(items []db.ToDoItem, depth int)
+	printItems = func(items []db.ToDoItem, depth int) {
+		for _, item := range items {
+			fmt.Println(strings.Repeat("    ", depth) + render.Line(item))
+			printItems(children[item.Id], depth+1)
+		}
+	}
+
+	lists := make(map[string][]db.ToDoItem)
+	var names []string
+	for _, item := range roots {
+		if _, ok := lists[item.List]; !ok {
+			names = append(names, item.List)
+		}
+		lists[item.List] = append(lists[item.List], item)
+	}
+	if len(names) <= 1 {
+		printItems(roots, 0)
+		return
+	}
+
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(name + ":")
+		printItems(lists[name], 1)
+	}
+}
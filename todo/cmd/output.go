@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"drexel.edu/todo/render"
+	"github.com/spf13/cobra"
+)
+
+// addOutputFlag registers the --output flag of the commands that show
+// items
+func addOutputFlag(c *cobra.Command, output *string) {
+	c.Flags().StringVarP(output, "output", "o", render.Auto,
+		"Output format: "+strings.Join(render.Modes, ", ")+" (auto is a table on a terminal, json otherwise)")
+	fromConfig(c.Flags(), "output", "output")
+}
+
+// newRenderer checks the --output flag before the database is opened,
+// so a typo is reported as a usage error
+func newRenderer(output string) (render.Renderer, error) {
+	return render.New(output, os.Stdout)
+}
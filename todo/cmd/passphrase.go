@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// The passphrase of an encrypted database is read from these environment
+// variables when they are set, so scripts do not have to answer a prompt
+const (
+	passphraseEnv    = "TODO_PASSPHRASE"
+	newPassphraseEnv = "TODO_NEW_PASSPHRASE"
+)
+
+// passphraseFor returns the function asked for the passphrase of dbFile
+// the first time it turns out to be encrypted, a database that is not
+// encrypted never asks
+func passphraseFor(dbFile string) func() (string, error) {
+	return func() (string, error) {
+		if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+			return passphrase, nil
+		}
+
+		return promptPassphrase(fmt.Sprintf("Passphrase for %s: ", dbFile), passphraseEnv)
+	}
+}
+
+// newPassphrase asks for the passphrase to encrypt the database with.  It
+// is asked twice as a typo here would lock the user out of the database.
+func newPassphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(newPassphraseEnv); ok {
+		if passphrase == "" {
+			return "", fmt.Errorf("%s is empty", newPassphraseEnv)
+		}
+		return passphrase, nil
+	}
+
+	passphrase, err := promptPassphrase("New passphrase: ", newPassphraseEnv)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("the passphrase cannot be empty")
+	}
+	again, err := promptPassphrase("Repeat the new passphrase: ", newPassphraseEnv)
+	if err != nil {
+		return "", err
+	}
+	if passphrase != again {
+		return "", errors.New("the passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+// promptPassphrase reads a passphrase from the terminal without showing
+// it.  Without a terminal to ask on, the passphrase has to come from env.
+func promptPassphrase(prompt string, env string) (string, error) {
+	fd := os.Stdin.Fd()
+	if !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd) {
+		return "", fmt.Errorf("cannot ask for the passphrase without a terminal, set %s", env)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	if restore, err := disableEcho(int(fd)); err == nil {
+		defer restore()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// resizeSignals is empty, there is no signal for a change of size here
+var resizeSignals []os.Signal
+
+// makeRaw is not supported here, so neither is todo tui
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("the terminal interface is not supported on this platform")
+}
+
+// terminalSize is not supported here
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errors.New("cannot get the terminal size on this platform")
+}
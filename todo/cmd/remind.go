@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/notify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	remindListFlag     string
+	remindTagFlag      []string
+	remindBeforeFlag   string
+	remindNotifyFlag   []string
+	remindDaemonFlag   bool
+	remindIntervalFlag time.Duration
+)
+
+var remindCmd = &cobra.Command{
+	Use:   "remind",
+	Short: "Send reminders about items coming due and overdue",
+	Long: `Send a reminder for every pending item that is due within --before, and
+another once it is overdue.  Each reminder is sent once: the ones that
+were sent are recorded in <db>.reminders, and moving the due date of an
+item arms its reminders again.
+
+--notify picks how reminders are sent, repeat it to send them several
+ways:
+  stdout               print them (the default)
+  desktop              pop them up with notify-send, or osascript on macOS
+  command=CMD          run CMD with the shell, with the reminder as JSON on
+                       its standard input and TODO_REMINDER, TODO_KIND,
+                       TODO_ITEM_ID and TODO_TITLE in its environment
+  webhook=URL          post the reminder as JSON to URL
+
+A reminder that could not be sent every way is tried again on the next
+run.  With --daemon todo keeps running and checks the database every
+--interval until it is stopped.`,
+	Example: `  todo remind
+  todo remind --before 1d --list work
+  todo remind --daemon --notify desktop
+  todo remind --daemon --interval 5m --notify webhook=http://localhost:8080/todo
+  todo remind --notify 'command=mail -s "$TODO_REMINDER" me@example.com < /dev/null'`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		opts, err := remindOptions()
+		if err != nil {
+			return err
+		}
+		if remindIntervalFlag <= 0 {
+			return fmt.Errorf("invalid --interval %s, it has to be more than 0", remindIntervalFlag)
+		}
+
+		notifiers := make([]notify.Notifier, 0, len(remindNotifyFlag))
+		for _, spec := range remindNotifyFlag {
+			n, err := notify.Parse(spec)
+			if err != nil {
+				return err
+			}
+			notifiers = append(notifiers, n)
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			if !remindDaemonFlag {
+				return sendReminders(context.Background(), todo, opts, notifiers)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			ticker := time.NewTicker(remindIntervalFlag)
+			defer ticker.Stop()
+			for {
+				//A database that cannot be read right now, for example
+				//because it is locked, is tried again on the next tick
+				if err := sendReminders(ctx, todo, opts, notifiers); err != nil && ctx.Err() == nil {
+					log.Printf("checking for reminders: %v", err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		})
+	},
+}
+
+func init() {
+	flags := remindCmd.Flags()
+	flags.StringVarP(&remindListFlag, "list", "l", "", "Only remind of items on this list (default all lists)")
+	flags.StringSliceVarP(&remindTagFlag, "tag", "t", nil, "Only remind of items with this tag (repeat to require several)")
+	flags.StringVar(&remindBeforeFlag, "before", "1h", "How long before the due date to remind, like 30m, 12h or 2d")
+	flags.StringArrayVar(&remindNotifyFlag, "notify", []string{notify.Stdout}, "How to send reminders: "+strings.Join(notify.Kinds, ", ")+" (repeatable)")
+	flags.BoolVar(&remindDaemonFlag, "daemon", false, "Keep running and check for reminders every --interval")
+	flags.DurationVar(&remindIntervalFlag, "interval", time.Minute, "How often --daemon checks for reminders")
+	rootCmd.AddCommand(remindCmd)
+}
+
+// remindOptions turns the remind flags into options for db.Reminders
+func remindOptions() (db.RemindOptions, error) {
+	var opts db.RemindOptions
+
+	if remindListFlag != "" {
+		list, err := db.ParseListName(remindListFlag)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter.List = list
+	}
+	opts.Filter.Tags = remindTagFlag
+
+	//The lead is an age, the same as the cutoffs of archive
+	now := time.Now()
+	cutoff, err := ageCutoff(remindBeforeFlag)
+	if err != nil {
+		return opts, fmt.Errorf("invalid --before %q, use for example 30m, 12h or 2d", remindBeforeFlag)
+	}
+	if !cutoff.IsZero() {
+		opts.Lead = now.Sub(cutoff)
+	}
+
+	return opts, nil
+}
+
+// sendReminders sends the reminders that are due through every notifier
+// and records the ones that went out every way
+func sendReminders(ctx context.Context, todo *db.ToDo, opts db.RemindOptions, notifiers []notify.Notifier) error {
+	now := time.Now()
+	reminders, err := todo.Reminders(opts, now)
+	if err != nil || len(reminders) == 0 {
+		return err
+	}
+
+	sent := make([]db.Reminder, 0, len(reminders))
+	var errs []error
+	for _, r := range reminders {
+		ok := true
+		for _, n := range notifiers {
+			if err := n.Notify(ctx, r); err != nil {
+				errs = append(errs, fmt.Errorf("reminder for item %d: %w", r.Item.Id, err))
+				ok = false
+			}
+		}
+		if ok {
+			sent = append(sent, r)
+		}
+	}
+
+	if len(sent) > 0 {
+		if err := todo.MarkReminded(sent, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
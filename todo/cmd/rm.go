@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var rmCmd = &cobra.Command{
+	Use:     "rm <id>...",
+	Aliases: []string{"delete"},
+	Short:   "Delete one or more items from the database",
+	Example: `  todo rm 4`,
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		ids, err := parseIds(args)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			for _, id := range ids {
+				if err := todo.DeleteItem(id); err != nil {
+					return fmt.Errorf("item %d: %w", id, err)
+				}
+				fmt.Println("Deleted item", id)
+			}
+			return nil
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+}
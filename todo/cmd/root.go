@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by Execute.  Scripts can tell a mistake in how the
+// command was typed apart from a problem with the database itself.
+const (
+	ExitOK    = 0
+	ExitDB    = 1
+	ExitUsage = 2
+)
+
+// dbFileNameFlag holds the --db flag shared by every subcommand
+var dbFileNameFlag string
+
+// rootCmd is the todo command itself.  It does nothing on its own, all
+// of the work is done by the subcommands registered in their own files.
+var rootCmd = &cobra.Command{
+	Use:   "todo",
+	Short: "Manage a list of todo items",
+	Long: `todo manages a list of todo items kept in a simple database file.
+
+By default the database is ./data/todo.json, use --db to pick another one,
+or set it once in the config file, see todo config.  Prefix the name with
+bolt:// to use the embedded key-value store instead of a JSON file.`,
+
+	// We print errors ourselves in Execute so usage errors and database
+	// errors can be reported differently
+	SilenceErrors: true,
+	SilenceUsage:  true,
+
+	// Check the flags shared by every subcommand before any of them gets
+	// to open the database
+	PersistentPreRunE: func(c *cobra.Command, args []string) error {
+		if err := applyConfig(c); err != nil {
+			return configError{err}
+		}
+
+		var err error
+		shellHooks, err = parseHooks(hookFlag)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&dbFileNameFlag, "db", "./data/todo.json",
+		"Name of the database file (prefix with bolt:// to use the key-value store)")
+	fromConfig(rootCmd.PersistentFlags(), "db", "db")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Profile of the config file to use, see todo config")
+	rootCmd.PersistentFlags().StringArrayVar(&hookFlag, "hook", nil,
+		"Run a shell command after each change, as event=command with event add, update, delete, done or * (repeatable)")
+	fromConfig(rootCmd.PersistentFlags(), "hook", "hooks")
+
+	rootCmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return err
+	})
+}
+
+// dbError marks errors that came from the database rather than from the
+// way the command was used
+type dbError struct {
+	err error
+}
+
+func (e dbError) Error() string { return e.err.Error() }
+func (e dbError) Unwrap() error { return e.err }
+
+// configError marks errors in the config file or the environment, which
+// are no more a usage error than a broken database is
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string { return e.err.Error() }
+func (e configError) Unwrap() error { return e.err }
+
+// Execute runs the todo command line and returns the process exit code.
+// Anything that goes wrong before we get to touch the database (unknown
+// commands, bad flags, missing or malformed arguments) is a usage error,
+// everything else, a bad config file included, is a database error.
+func Execute() int {
+	c, err := rootCmd.ExecuteC()
+	if err == nil {
+		return ExitOK
+	}
+
+	var dbErr dbError
+	var confErr configError
+	if errors.As(err, &dbErr) || errors.As(err, &confErr) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return ExitDB
+	}
+
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprint(os.Stderr, c.UsageString())
+	return ExitUsage
+}
+
+// withDB opens the database named by --db, runs fn against it and closes
+// it again.  If the database is encrypted the passphrase is asked for the
+// first time it is needed, see passphraseFor, and the --hook commands run
+// after every change.  Every error coming out of here is reported as a
+// database error.
+func withDB(fn func(todo *db.ToDo) error) error {
+	//A database that does not exist is created empty, which is easy to
+	//do by mistake by running todo in another directory
+	if _, path := db.ParseDbURI(dbFileNameFlag); !fileExists(path) {
+		fmt.Fprintf(os.Stderr, "Creating a new empty database %s\n", path)
+	}
+
+	todo, err := db.New(dbFileNameFlag)
+	if err != nil {
+		return dbError{err}
+	}
+	defer todo.Close()
+	todo.SetPassphraseFunc(passphraseFor(dbFileNameFlag))
+	addShellHooks(todo, shellHooks)
+
+	if err := fn(todo); err != nil {
+		if errors.Is(err, db.ErrCorruptDB) {
+			err = fmt.Errorf("%w\nRun todo fsck to see what is wrong, and todo fsck --repair to fix it", err)
+		}
+		return dbError{err}
+	}
+
+	return nil
+}
+
+// parseIds converts positional arguments into item ids
+func parseIds(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item id %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// plural counts n of something, as in 1 item or 3 items
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"drexel.edu/todo/api"
+	"drexel.edu/todo/db"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveHostFlag string
+	servePortFlag uint
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the database over a REST API",
+	Long: `Serve the database over a REST API so other programs (dashboards,
+scripts, ...) can read and change the same database the CLI uses.
+
+The host and port can also be set with the TODOAPI_HOST and TODOAPI_PORT
+environment variables, --host and --port beat them.
+
+Routes:
+  GET    /todo-api/items                       list items, filtered by the
+                                               same query parameters as ls
+                                               (list, done, archived, tag, sort, ...)
+  GET    /todo-api/items/:id                   get an item
+  POST   /todo-api/items                       add an item from a JSON body
+  PUT    /todo-api/items/:id                   update an item from a JSON body
+  DELETE /todo-api/items/:id                   delete an item
+  PUT    /todo-api/items/:id/done/:status      set the done status (true/false),
+                                               ?force=true ignores blockers
+  POST   /todo-api/items/:id/toggle            flip the done status
+  GET    /todo-api/lists                       the lists with counts of their items
+  GET    /todo-api/health                      health check`,
+	Example: `  todo serve --port 1080
+  curl 'localhost:1080/todo-api/items?done=false&tag=work&sort=due'`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		//A flag beats the environment, as for every other setting
+		if !c.Flags().Changed("host") {
+			serveHostFlag = envVarOrDefault("TODOAPI_HOST", serveHostFlag)
+		}
+		if !c.Flags().Changed("port") {
+			port, err := strconv.Atoi(envVarOrDefault("TODOAPI_PORT", fmt.Sprintf("%d", servePortFlag)))
+			// only update port if env var converts to int successfully - else use default
+			if err == nil {
+				servePortFlag = uint(port)
+			}
+		}
+
+		apiHandler, err := api.NewToDoApi(dbFileNameFlag)
+		if err != nil {
+			return dbError{err}
+		}
+		defer apiHandler.Close()
+		if err := apiHandler.Unlock(passphraseFor(dbFileNameFlag)); err != nil {
+			return dbError{err}
+		}
+		for _, h := range shellHooks {
+			apiHandler.AddHook(db.ShellHook(h.command), h.events...)
+		}
+
+		r := gin.Default()
+		r.Use(cors.Default())
+
+		r.GET("/todo-api/items", apiHandler.ListItems)
+		r.GET("/todo-api/items/:id", apiHandler.GetItem)
+		r.GET("/todo-api/lists", apiHandler.ListLists)
+		r.GET("/todo-api/health", apiHandler.HealthCheck)
+
+		r.POST("/todo-api/items", apiHandler.AddItem)
+		r.POST("/todo-api/items/:id/toggle", apiHandler.ToggleItemDone)
+
+		r.PUT("/todo-api/items/:id", apiHandler.UpdateItem)
+		r.PUT("/todo-api/items/:id/done/:status", apiHandler.SetItemDone)
+
+		r.DELETE("/todo-api/items/:id", apiHandler.DeleteItem)
+
+		serverPath := fmt.Sprintf("%s:%d", serveHostFlag, servePortFlag)
+		return r.Run(serverPath)
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveHostFlag, "host", "0.0.0.0", "Interface to listen on")
+	serveCmd.Flags().UintVar(&servePortFlag, "port", 1080, "Port to listen on")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func envVarOrDefault(envVar string, defaultVal string) string {
+	envVal := os.Getenv(envVar)
+	if envVal != "" {
+		return envVal
+	}
+
+	return defaultVal
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsListFlag   string
+	statsTagFlag    []string
+	statsPeriodFlag string
+	statsSinceFlag  string
+	statsJSONFlag   bool
+)
+
+// burndownWidth is the length of the longest bar of the burndown chart
+const burndownWidth = 40
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how many items get created and done over time",
+	Long: `Show totals for the items in the database, the average time it took to
+get an item done, and per day or week how many items were created and
+completed.  The burndown column charts the items still open at the end
+of each period.
+
+Archived items are counted, deleted ones are gone and are not.  Use
+--json to feed the numbers to another tool.`,
+	Example: `  todo stats
+  todo stats --period week --since 12w
+  todo stats --list work --since 2023-09-01
+  todo stats --json`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		opts, err := statsOptions()
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			stats, err := todo.Stats(opts)
+			if err != nil {
+				return err
+			}
+
+			if statsJSONFlag {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(stats)
+			}
+
+			printStats(stats)
+			return nil
+		})
+	},
+}
+
+func init() {
+	flags := statsCmd.Flags()
+	flags.StringVarP(&statsListFlag, "list", "l", "", "Only count items on this list (default all lists)")
+	flags.StringSliceVarP(&statsTagFlag, "tag", "t", nil, "Only count items with this tag (repeat to require several)")
+	flags.StringVar(&statsPeriodFlag, "period", string(db.StatsDay), "Count per day or week")
+	flags.StringVar(&statsSinceFlag, "since", "", "First day to show, a date or an age like 30d (default 14 days or 8 weeks ago)")
+	flags.BoolVar(&statsJSONFlag, "json", false, "Print the numbers as JSON")
+	rootCmd.AddCommand(statsCmd)
+}
+
+// statsOptions turns the stats flags into options for db.Stats
+func statsOptions() (db.StatsOptions, error) {
+	var opts db.StatsOptions
+
+	if statsListFlag != "" {
+		list, err := db.ParseListName(statsListFlag)
+		if err != nil {
+			return opts, err
+		}
+		opts.Filter.List = list
+	}
+	opts.Filter.Tags = statsTagFlag
+
+	var err error
+	if opts.Period, err = db.ParseStatsPeriod(statsPeriodFlag); err != nil {
+		return opts, err
+	}
+
+	switch {
+	case statsSinceFlag == "" && opts.Period == db.StatsWeek:
+		opts.Since = time.Now().AddDate(0, 0, -7*7)
+	case statsSinceFlag == "":
+		opts.Since = time.Now().AddDate(0, 0, -13)
+	default:
+		//A date, or failing that an age
+		since, dateErr := db.ParseDate(statsSinceFlag)
+		if dateErr == nil {
+			opts.Since = *since
+		} else if opts.Since, err = ageCutoff(statsSinceFlag); err != nil {
+			return opts, fmt.Errorf("invalid --since %q, use a date or an age like 30d", statsSinceFlag)
+		}
+	}
+
+	return opts, nil
+}
+
+func printStats(stats db.Stats) {
+	fmt.Printf("%-16s %d total, %d open, %d done, %d overdue, %d archived\n",
+		"Items:", stats.Total, stats.Open, stats.Done, stats.Overdue, stats.Archived)
+	if stats.Done > 0 {
+		fmt.Printf("%-16s %s\n", "Time to done:", formatAge(stats.AvgTimeToDone())+" on average")
+	}
+	if len(stats.Periods) == 0 {
+		return
+	}
+
+	most := 0
+	for _, p := range stats.Periods {
+		if p.Open > most {
+			most = p.Open
+		}
+	}
+
+	layout := "2006-01-02"
+	label := strings.ToUpper(string(stats.Period))
+	fmt.Println()
+	fmt.Printf("%-10s %8s %6s %6s  %s\n", label, "CREATED", "DONE", "OPEN", "BURNDOWN")
+	for _, p := range stats.Periods {
+		bar := 0
+		if most > 0 {
+			bar = (p.Open*burndownWidth + most - 1) / most
+		}
+		fmt.Printf("%-10s %8d %6d %6d  %s\n", p.Start.Format(layout), p.Created, p.Completed, p.Open, strings.Repeat("#", bar))
+	}
+}
+
+// formatAge shows a duration in days and hours, or hours and minutes
+// when it is less than a day
+func formatAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Round(time.Minute).String()
+	}
+
+	d = d.Round(time.Hour)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	return fmt.Sprintf("%dd %dh", days, hours)
+}
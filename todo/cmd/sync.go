@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncPolicyFlag string
+	syncDryRunFlag bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <other-db>",
+	Short: "Merge another copy of the database into this one, and back",
+	Long: `Merge the changes made to another copy of the database with the changes
+made to this one, and save the result to both, so they end up the same.
+
+The merge is three-way: each sync remembers the merged items, and the
+next one only takes what changed on each side since.  A field changed on
+one side takes that side's value.  A field changed on both sides is a
+conflict, settled by --policy:
+
+  newer    keep the value of the side where the item was updated last
+  ours     keep the value in this database
+  theirs   keep the value in the other database
+  fail     report the conflicts and change nothing
+
+An item deleted on one side and changed on the other is kept by newer.
+Items added on both sides under the same id are both kept, the other
+side's one gets a new id.  The first sync between two files has nothing
+to go on, so every difference is a conflict.
+
+Each side records the sync as one change, so it can be undone on either
+side.  Use --dry-run to see what would happen.`,
+	Example: `  todo sync ~/Dropbox/todo.json
+  todo sync --dry-run --policy fail laptop.json
+  todo sync --policy theirs bolt://./shared.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(c *cobra.Command, args []string) error {
+		policy, err := db.ParseSyncPolicy(syncPolicyFlag)
+		if err != nil {
+			return err
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			other, err := db.New(args[0])
+			if err != nil {
+				return err
+			}
+			defer other.Close()
+			other.SetPassphraseFunc(passphraseFor(args[0]))
+
+			report, err := todo.Sync(other, db.SyncOptions{Policy: policy, DryRun: syncDryRunFlag})
+			if errors.Is(err, db.ErrSyncConflict) {
+				printSyncConflicts(report.Conflicts)
+			}
+			if err != nil {
+				return err
+			}
+
+			printSyncReport(args[0], report)
+			return nil
+		})
+	},
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&syncPolicyFlag, "policy", string(db.SyncNewer), "How to settle conflicts: newer, ours, theirs or fail")
+	syncCmd.Flags().BoolVarP(&syncDryRunFlag, "dry-run", "n", false, "Report what would change without changing either database")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func printSyncReport(other string, report db.SyncReport) {
+	verb := "Synced"
+	if report.DryRun {
+		verb = "Would sync"
+	}
+	fmt.Printf("%s with %s: %d pulled, %d pushed, %d conflicts\n",
+		verb, other, len(report.Pulled), len(report.Pushed), len(report.Conflicts))
+	if report.FirstSync {
+		fmt.Println("  first sync with this file, every field that differs is a conflict")
+	}
+
+	printIdList("pulled", report.Pulled)
+	printIdList("pushed", report.Pushed)
+
+	if len(report.Renumbered) > 0 {
+		moves := make([]string, len(report.Renumbered))
+		for i, r := range report.Renumbered {
+			moves[i] = fmt.Sprintf("%d -> %d", r.From, r.To)
+		}
+		fmt.Printf("  %-12s %s\n", "renumbered", strings.Join(moves, ", "))
+	}
+
+	printSyncConflicts(report.Conflicts)
+
+	for _, p := range report.Fixed {
+		fmt.Printf("  %-12s %s (%s)\n", "fixed", p, p.Fix)
+	}
+}
+
+func printSyncConflicts(conflicts []db.Conflict) {
+	for _, conflict := range conflicts {
+		line := fmt.Sprintf("item %d %s: ours %s, theirs %s", conflict.Id, conflict.Field, conflict.Ours, conflict.Theirs)
+		if conflict.Winner != "" {
+			line += ", kept " + conflict.Winner
+		}
+		fmt.Printf("  %-12s %s\n", "conflict", line)
+	}
+}
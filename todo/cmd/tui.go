@@ -0,0 +1,775 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"drexel.edu/todo/db"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuiListFlag    string
+	tuiSortFlag    string
+	tuiPendingFlag bool
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse and change the items in a full screen terminal interface",
+	Long: `Browse and change the items in a full screen terminal interface.  The
+screen follows the database file, changes made by other processes show
+up as they are saved.  Archived items are not shown.
+
+Keys:
+  up/down, j/k           move, page up/down and home/end jump
+  space, x               toggle the done status
+  enter, e               edit the title in place
+  a                      add an item, to --list if it is given
+  d, delete              delete the item, after asking
+  /                      filter by title as you type, esc clears it
+  p                      hide or show the done items
+  r                      reload the database
+  q, ctrl-c              quit
+
+While typing, enter saves, esc cancels, and left/right, home/end,
+backspace, delete and ctrl-u edit the text.`,
+	Example: `  todo tui
+  todo tui --list work --pending --sort due`,
+	Args: cobra.NoArgs,
+	RunE: func(c *cobra.Command, args []string) error {
+		q := db.Query{Archived: new(bool)}
+		if tuiListFlag != "" {
+			list, err := db.ParseListName(tuiListFlag)
+			if err != nil {
+				return err
+			}
+			q.List = list
+		}
+		sortKey, err := db.ParseSortKey(tuiSortFlag)
+		if err != nil {
+			return err
+		}
+		q.SortBy = sortKey
+
+		in, out := os.Stdin.Fd(), os.Stdout.Fd()
+		if !isatty.IsTerminal(in) || !isatty.IsTerminal(out) {
+			return errors.New("todo tui needs a terminal, use ls to list the items")
+		}
+
+		return withDB(func(todo *db.ToDo) error {
+			ui := &tui{todo: todo, query: q, pending: tuiPendingFlag, color: os.Getenv("NO_COLOR") == ""}
+			return ui.run(int(in), os.Stdout)
+		})
+	},
+}
+
+func init() {
+	flags := tuiCmd.Flags()
+	flags.StringVarP(&tuiListFlag, "list", "l", "", "Only show items on this list, and add items to it (default all lists)")
+	flags.StringVar(&tuiSortFlag, "sort", string(db.SortById), "Sort by id, due, priority, title, created or updated")
+	fromConfig(flags, "sort", "sort")
+	flags.BoolVarP(&tuiPendingFlag, "pending", "p", false, "Start with the done items hidden")
+	rootCmd.AddCommand(tuiCmd)
+}
+
+// tuiMode is what the keys of the terminal interface do at the moment
+type tuiMode int
+
+const (
+	tuiBrowse tuiMode = iota
+	tuiFilter
+	tuiAdd
+	tuiEdit
+	tuiConfirmDelete
+)
+
+// tui is the state of the terminal interface
+type tui struct {
+	todo    *db.ToDo
+	query   db.Query
+	pending bool
+	color   bool
+
+	width, height int
+
+	// items are the items on screen, selected indexes them and top is
+	// the first one shown
+	items    []db.ToDoItem
+	selected int
+	top      int
+	filter   string
+
+	mode  tuiMode
+	input lineEditor
+	// target is the item being edited or asked about deleting, it is kept
+	// apart from the selection, which a reload can move
+	target db.ToDoItem
+
+	status    string
+	statusErr bool
+}
+
+// run shows the interface on the terminal in and out until the user quits
+func (ui *tui) run(in int, out io.Writer) error {
+	//Load before the terminal goes raw, so a passphrase can still be
+	//asked for
+	if err := ui.reload(); err != nil {
+		return err
+	}
+	ui.resize(in)
+
+	restore, err := makeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	//The alternate screen keeps the shell's screen as it was
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := ui.todo.Watch(ctx, db.DefaultWatchInterval)
+	keys := readKeys(os.Stdin)
+
+	resized := make(chan os.Signal, 1)
+	if len(resizeSignals) > 0 {
+		signal.Notify(resized, resizeSignals...)
+		defer signal.Stop(resized)
+	}
+
+	for {
+		ui.draw(out)
+
+		select {
+		case k, ok := <-keys:
+			if !ok || ui.handle(k) {
+				return nil
+			}
+		case err := <-changes:
+			//Set before the reload, which may have an error to show
+			if ui.mode == tuiBrowse {
+				ui.setStatus("The database changed on disk and was reloaded")
+			}
+			if err == nil {
+				err = ui.reload()
+			}
+			if err != nil {
+				ui.setError(err)
+			}
+		case <-resized:
+			ui.resize(in)
+		}
+	}
+}
+
+// reload reads the items again, keeping the same item selected if it is
+// still there
+func (ui *tui) reload() error {
+	q := ui.query
+	q.Title = ui.filter
+	if ui.pending {
+		q.Done = new(bool)
+	}
+
+	items, err := ui.todo.Find(q)
+	if err != nil {
+		return err
+	}
+
+	id := ui.selectedId()
+	ui.items = items
+	ui.selectId(id)
+
+	//An edit or delete is only carried out on the item it was started on
+	if ui.mode == tuiEdit || ui.mode == tuiConfirmDelete {
+		item, ok := ui.find(ui.target.Id)
+		if !ok {
+			ui.mode = tuiBrowse
+			ui.setError(fmt.Errorf("item %d is gone, nothing was changed", ui.target.Id))
+			return nil
+		}
+		ui.target = item
+		ui.selectId(item.Id)
+	}
+	return nil
+}
+
+// find returns the item with id if it is on screen
+func (ui *tui) find(id int) (db.ToDoItem, bool) {
+	for _, item := range ui.items {
+		if item.Id == id {
+			return item, true
+		}
+	}
+	return db.ToDoItem{}, false
+}
+
+func (ui *tui) resize(fd int) {
+	ui.width, ui.height = 80, 24
+	if w, h, err := terminalSize(fd); err == nil && w > 0 && h > 0 {
+		ui.width, ui.height = w, h
+	}
+}
+
+func (ui *tui) selectedId() int {
+	if ui.selected < len(ui.items) {
+		return ui.items[ui.selected].Id
+	}
+	return 0
+}
+
+// selectId selects the item with id, or the nearest row if it is gone
+func (ui *tui) selectId(id int) {
+	for i, item := range ui.items {
+		if item.Id == id {
+			ui.selected = i
+			return
+		}
+	}
+	ui.move(0)
+}
+
+// move moves the selection by n rows, staying on the list
+func (ui *tui) move(n int) {
+	ui.selected += n
+	if ui.selected >= len(ui.items) {
+		ui.selected = len(ui.items) - 1
+	}
+	if ui.selected < 0 {
+		ui.selected = 0
+	}
+}
+
+func (ui *tui) setStatus(format string, args ...any) {
+	ui.status, ui.statusErr = fmt.Sprintf(format, args...), false
+}
+
+func (ui *tui) setError(err error) {
+	ui.status, ui.statusErr = err.Error(), true
+}
+
+// listRows is how many rows of items fit between the header and the two
+// lines at the bottom
+func (ui *tui) listRows() int {
+	if rows := ui.height - 3; rows > 0 {
+		return rows
+	}
+	return 1
+}
+
+// handle acts on a key, it returns true when the user quits
+func (ui *tui) handle(k key) bool {
+	switch ui.mode {
+	case tuiBrowse:
+		return ui.handleBrowse(k)
+	case tuiConfirmDelete:
+		ui.mode = tuiBrowse
+		if k.r == 'y' || k.r == 'Y' {
+			id := ui.target.Id
+			if err := ui.todo.DeleteItem(id); err != nil {
+				ui.setError(err)
+			} else {
+				ui.setStatus("Deleted item %d", id)
+			}
+			ui.refresh()
+		} else {
+			ui.setStatus("Nothing was deleted")
+		}
+		return false
+	}
+
+	//The other modes type text into the input line
+	switch k.name {
+	case "esc":
+		if ui.mode == tuiFilter {
+			ui.filter = ""
+			ui.refresh()
+		}
+		ui.mode = tuiBrowse
+		ui.setStatus("")
+	case "enter":
+		ui.submit()
+	case "up", "down":
+		if ui.mode == tuiFilter {
+			ui.handleBrowse(k)
+		}
+	case "ctrl-c":
+		return true
+	default:
+		if ui.input.handle(k) && ui.mode == tuiFilter {
+			ui.filter = ui.input.String()
+			ui.refresh()
+		}
+	}
+	return false
+}
+
+func (ui *tui) handleBrowse(k key) bool {
+	switch {
+	case k.r == 'q' || k.name == "ctrl-c":
+		return true
+	case k.r == 'k' || k.name == "up":
+		ui.move(-1)
+	case k.r == 'j' || k.name == "down":
+		ui.move(1)
+	case k.name == "pgup":
+		ui.move(-ui.listRows())
+	case k.name == "pgdn":
+		ui.move(ui.listRows())
+	case k.r == 'g' || k.name == "home":
+		ui.move(-len(ui.items))
+	case k.r == 'G' || k.name == "end":
+		ui.move(len(ui.items))
+	case k.r == ' ' || k.r == 'x':
+		ui.toggle()
+	case k.r == 'e' || k.name == "enter":
+		if len(ui.items) > 0 {
+			ui.mode = tuiEdit
+			ui.target = ui.items[ui.selected]
+			ui.input.set(ui.target.Title)
+			ui.setStatus("Editing item %d, enter saves, esc cancels", ui.target.Id)
+		}
+	case k.r == 'a':
+		ui.mode = tuiAdd
+		ui.input.set("")
+		ui.setStatus("Type the title of the new item, enter adds it, esc cancels")
+	case k.r == 'd' || k.name == "delete":
+		if len(ui.items) > 0 {
+			ui.mode = tuiConfirmDelete
+			ui.target = ui.items[ui.selected]
+			ui.setStatus("")
+		}
+	case k.r == '/':
+		ui.mode = tuiFilter
+		ui.input.set(ui.filter)
+		ui.setStatus("Type to filter by title, enter keeps the filter, esc clears it")
+	case k.r == 'p':
+		ui.pending = !ui.pending
+		ui.refresh()
+	case k.r == 'r':
+		if err := ui.todo.Reload(); err != nil {
+			ui.setError(err)
+			return false
+		}
+		ui.refresh()
+		ui.setStatus("Reloaded")
+	case k.name == "esc" && ui.filter != "":
+		ui.filter = ""
+		ui.refresh()
+	}
+	return false
+}
+
+// refresh reloads the items after a change, showing what went wrong
+func (ui *tui) refresh() {
+	if err := ui.reload(); err != nil {
+		ui.setError(err)
+	}
+}
+
+// toggle flips the done status of the selected item
+func (ui *tui) toggle() {
+	if len(ui.items) == 0 {
+		return
+	}
+
+	item := ui.items[ui.selected]
+	if err := ui.todo.ChangeItemDoneStatus(item.Id, !item.IsDone); err != nil {
+		ui.setError(fmt.Errorf("item %d: %w", item.Id, err))
+	} else if item.IsDone {
+		ui.setStatus("Marked item %d not done", item.Id)
+	} else {
+		ui.setStatus("Marked item %d done", item.Id)
+	}
+	ui.refresh()
+}
+
+// submit saves what was typed in the add and edit modes
+func (ui *tui) submit() {
+	mode := ui.mode
+	ui.mode = tuiBrowse
+	title := strings.TrimSpace(ui.input.String())
+
+	switch mode {
+	case tuiFilter:
+		ui.setStatus("")
+		return
+	case tuiAdd:
+		if title == "" {
+			ui.setStatus("Nothing was added")
+			return
+		}
+		id, err := ui.todo.AddItem(db.ToDoItem{Title: title, List: ui.query.List})
+		if err != nil {
+			ui.setError(err)
+			return
+		}
+		ui.setStatus("Added item %d", id)
+		ui.refresh()
+		ui.selectId(id)
+	case tuiEdit:
+		if title == "" {
+			ui.setError(errors.New("the title cannot be empty, nothing was changed"))
+			return
+		}
+		id := ui.target.Id
+		err := ui.todo.Batch(func(b *db.Batch) error {
+			item, err := b.Get(id)
+			if err != nil {
+				return err
+			}
+			item.Title = title
+			return b.Update(item)
+		})
+		if err != nil {
+			ui.setError(fmt.Errorf("item %d: %w", id, err))
+		} else {
+			ui.setStatus("Updated item %d", id)
+		}
+		ui.refresh()
+	}
+}
+
+// draw paints the whole screen
+func (ui *tui) draw(out io.Writer) {
+	var b strings.Builder
+	rows := ui.listRows()
+
+	//Keep the selection on screen
+	if ui.selected < ui.top {
+		ui.top = ui.selected
+	}
+	if ui.selected >= ui.top+rows {
+		ui.top = ui.selected - rows + 1
+	}
+	if ui.top > 0 && ui.top+rows > len(ui.items) {
+		ui.top = len(ui.items) - rows
+		if ui.top < 0 {
+			ui.top = 0
+		}
+	}
+
+	open := 0
+	for _, item := range ui.items {
+		if !item.IsDone {
+			open++
+		}
+	}
+	header := fmt.Sprintf(" todo  %s  %d items, %d open", dbFileNameFlag, len(ui.items), open)
+	if ui.query.List != "" {
+		header += "  list " + ui.query.List
+	}
+	if ui.filter != "" {
+		header += fmt.Sprintf("  filter %q", ui.filter)
+	}
+	if ui.pending {
+		header += "  done hidden"
+	}
+	ui.line(&b, 1, padRight(header, ui.width), "\x1b[7m")
+
+	cursorRow, cursorCol := 0, 0
+	for i := 0; i < rows; i++ {
+		idx := ui.top + i
+		if idx >= len(ui.items) {
+			ui.line(&b, i+2, "", "")
+			if idx == 0 && i == 0 {
+				ui.line(&b, 2, "  No items, press a to add one", "\x1b[2m")
+			}
+			continue
+		}
+
+		item := ui.items[idx]
+		prefix := fmt.Sprintf("  %s %4d  ", doneBox(item.IsDone), item.Id)
+		text := prefix + item.Title + ui.details(item)
+		if ui.mode == tuiEdit && item.Id == ui.target.Id {
+			view, col := ui.input.view(ui.width - len(prefix) - 1)
+			text = prefix + view
+			cursorRow, cursorCol = i+2, len(prefix)+col+1
+		}
+
+		style := ""
+		switch {
+		case idx == ui.selected:
+			style = "\x1b[7m"
+			text = padRight(text, ui.width)
+		case item.IsDone && ui.color:
+			style = "\x1b[2m"
+		case item.Due != nil && item.Due.Before(time.Now()) && ui.color:
+			style = "\x1b[31m"
+		}
+		if idx == ui.selected {
+			text = ">" + text[1:]
+		}
+		ui.line(&b, i+2, text, style)
+	}
+
+	statusStyle := ""
+	if ui.statusErr && ui.color {
+		statusStyle = "\x1b[31m"
+	}
+	ui.line(&b, ui.height-1, ui.status, statusStyle)
+
+	var footer string
+	switch ui.mode {
+	case tuiBrowse:
+		footer = " j/k move  space done  e edit  a add  d delete  / filter  p hide done  r reload  q quit"
+	case tuiConfirmDelete:
+		footer = fmt.Sprintf(" Delete item %d %q? y/n", ui.target.Id, ui.target.Title)
+	case tuiFilter, tuiAdd:
+		prompt := " /"
+		if ui.mode == tuiAdd {
+			prompt = " Add: "
+		}
+		view, col := ui.input.view(ui.width - len(prompt) - 1)
+		footer = prompt + view
+		cursorRow, cursorCol = ui.height, len(prompt)+col+1
+	case tuiEdit:
+		footer = " enter save  esc cancel  ctrl-u clear"
+	}
+	ui.line(&b, ui.height, footer, "\x1b[1m")
+
+	if cursorRow > 0 {
+		fmt.Fprintf(&b, "\x1b[%d;%dH\x1b[?25h", cursorRow, cursorCol)
+	} else {
+		b.WriteString("\x1b[?25l")
+	}
+	io.WriteString(out, b.String())
+}
+
+// line writes one row of the screen, cut to the width of the terminal
+func (ui *tui) line(b *strings.Builder, row int, text, style string) {
+	fmt.Fprintf(b, "\x1b[%d;1H\x1b[2K", row)
+	if !ui.color && style != "\x1b[7m" {
+		style = ""
+	}
+	b.WriteString(style)
+	b.WriteString(truncate(text, ui.width))
+	if style != "" {
+		b.WriteString("\x1b[0m")
+	}
+}
+
+// details is what is shown after the title of an item
+func (ui *tui) details(item db.ToDoItem) string {
+	var parts []string
+	if item.Due != nil {
+		due := item.Due.Local()
+		layout := "2006-01-02 15:04"
+		if due.Hour() == 0 && due.Minute() == 0 {
+			layout = "2006-01-02"
+		}
+		parts = append(parts, "due "+due.Format(layout))
+	}
+	if item.Priority != db.PriorityNone {
+		parts = append(parts, "!"+item.Priority.String())
+	}
+	for _, tag := range item.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if ui.query.List == "" && item.List != db.DefaultList {
+		parts = append(parts, "@"+item.List)
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "  " + strings.Join(parts, " ")
+}
+
+func doneBox(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+// truncate cuts s to width characters, dropping control characters so a
+// title cannot mess up the screen
+func truncate(s string, width int) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		if n >= width {
+			break
+		}
+		if unicode.IsControl(r) {
+			r = ' '
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// lineEditor is the text typed into the input line
+type lineEditor struct {
+	text []rune
+	pos  int
+}
+
+func (e *lineEditor) set(s string) {
+	e.text = []rune(s)
+	e.pos = len(e.text)
+}
+
+func (e *lineEditor) String() string {
+	return string(e.text)
+}
+
+// handle applies an editing key, it returns true if the text changed
+func (e *lineEditor) handle(k key) bool {
+	switch k.name {
+	case "":
+		e.text = append(e.text[:e.pos], append([]rune{k.r}, e.text[e.pos:]...)...)
+		e.pos++
+		return true
+	case "backspace":
+		if e.pos > 0 {
+			e.text = append(e.text[:e.pos-1], e.text[e.pos:]...)
+			e.pos--
+			return true
+		}
+	case "delete", "ctrl-d":
+		if e.pos < len(e.text) {
+			e.text = append(e.text[:e.pos], e.text[e.pos+1:]...)
+			return true
+		}
+	case "ctrl-u":
+		changed := len(e.text) > 0
+		e.set("")
+		return changed
+	case "left", "ctrl-b":
+		if e.pos > 0 {
+			e.pos--
+		}
+	case "right", "ctrl-f":
+		if e.pos < len(e.text) {
+			e.pos++
+		}
+	case "home", "ctrl-a":
+		e.pos = 0
+	case "end", "ctrl-e":
+		e.pos = len(e.text)
+	}
+	return false
+}
+
+// view is the part of the text that fits in width, scrolled so the cursor
+// is in it, and the column of the cursor in that part
+func (e *lineEditor) view(width int) (string, int) {
+	if width < 1 {
+		width = 1
+	}
+	start := 0
+	if e.pos >= width {
+		start = e.pos - width + 1
+	}
+	end := start + width
+	if end > len(e.text) {
+		end = len(e.text)
+	}
+	return string(e.text[start:end]), e.pos - start
+}
+
+// key is a key pressed on the terminal: r is set for a character and
+// name for the other keys, like up, enter or ctrl-c
+type key struct {
+	r    rune
+	name string
+}
+
+// readKeys reads the keys typed on the terminal until it fails
+func readKeys(in io.Reader) <-chan key {
+	keys := make(chan key)
+
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			for _, k := range parseKeys(buf[:n]) {
+				keys <- k
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return keys
+}
+
+// csiKeys names the escape sequences of the special keys, without the
+// leading ESC [ or ESC O
+var csiKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"2~": "insert", "3~": "delete", "5~": "pgup", "6~": "pgdn",
+}
+
+// parseKeys splits what was read from the terminal into keys.  A whole
+// escape sequence comes in one read, so an ESC that ends the input is
+// the escape key itself.
+func parseKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		c := data[0]
+		switch {
+		case c == 0x1b && len(data) > 2 && (data[1] == '[' || data[1] == 'O'):
+			end := 2
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+				end++
+			}
+			if end == len(data) {
+				end--
+			}
+			keys = append(keys, key{name: csiKeys[string(data[2:end+1])]})
+			data = data[end+1:]
+			continue
+		case c == 0x1b:
+			keys = append(keys, key{name: "esc"})
+		case c == '\r' || c == '\n':
+			keys = append(keys, key{name: "enter"})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{name: "backspace"})
+		case c == '\t':
+			keys = append(keys, key{name: "tab"})
+		case c < 0x20:
+			keys = append(keys, key{name: "ctrl-" + string(rune('a'+c-1))})
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, key{r: r})
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+
+	//Unknown sequences give a key without a name, which does nothing
+	known := keys[:0]
+	for _, k := range keys {
+		if k.r != 0 || k.name != "" {
+			known = append(known, k)
+		}
+	}
+	return known
+}
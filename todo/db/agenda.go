@@ -0,0 +1,92 @@
+package db
+
+import (
+	"sort"
+	"time"
+)
+
+// Agenda sorts the pending items by when they are due.  Every bucket is
+// sorted by due date and then by id.
+type Agenda struct {
+	Overdue []ToDoItem `json:"overdue"`
+	Today   []ToDoItem `json:"today"`
+	// ThisWeek is due in the seven days after today
+	ThisWeek []ToDoItem `json:"thisWeek"`
+	Later    []ToDoItem `json:"later"`
+	// NoDue are the pending items without a due date
+	NoDue []ToDoItem `json:"noDue,omitempty"`
+}
+
+// Agenda puts the items that match filter, are not done and are not
+// archived into the buckets of an Agenda as of now.  The sorting and
+// paging of the filter are ignored.
+func (t *ToDo) Agenda(filter Query, now time.Time) (Agenda, error) {
+	agenda := Agenda{Overdue: []ToDoItem{}, Today: []ToDoItem{}, ThisWeek: []ToDoItem{}, Later: []ToDoItem{}}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadDB(); err != nil {
+		return agenda, err
+	}
+
+	today := periodStart(now, StatsDay)
+	weekEnd := today.AddDate(0, 0, 8)
+	for _, item := range t.toDoMap {
+		if item.IsDone || item.ArchivedAt != nil || !filter.Match(item) {
+			continue
+		}
+
+		switch {
+		case item.Due == nil:
+			agenda.NoDue = append(agenda.NoDue, item)
+		case !dueDeadline(*item.Due).After(now):
+			agenda.Overdue = append(agenda.Overdue, item)
+		case item.Due.Before(today.AddDate(0, 0, 1)):
+			agenda.Today = append(agenda.Today, item)
+		case item.Due.Before(weekEnd):
+			agenda.ThisWeek = append(agenda.ThisWeek, item)
+		default:
+			agenda.Later = append(agenda.Later, item)
+		}
+	}
+
+	for _, bucket := range [][]ToDoItem{agenda.Overdue, agenda.Today, agenda.ThisWeek, agenda.Later, agenda.NoDue} {
+		sortByDue(bucket)
+	}
+
+	return agenda, nil
+}
+
+// IsOverdue reports whether the item is still open after its due date
+// as of now.  An item due on a day without a time is overdue once that
+// day is over, so lists, stats, the agenda and reminders all agree.
+func (item ToDoItem) IsOverdue(now time.Time) bool {
+	return !item.IsDone && item.Due != nil && !dueDeadline(*item.Due).After(now)
+}
+
+// dueDeadline is when an item due at due becomes overdue.  A due date
+// without a time, which is midnight, lasts until the end of that day.
+func dueDeadline(due time.Time) time.Time {
+	due = due.Local()
+	if due.Hour() == 0 && due.Minute() == 0 && due.Second() == 0 {
+		return due.AddDate(0, 0, 1)
+	}
+
+	return due
+}
+
+// sortByDue sorts items by due date, those without one last, and then
+// by id
+func sortByDue(items []ToDoItem) {
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Due != nil && b.Due != nil && !a.Due.Equal(*b.Due) {
+			return a.Due.Before(*b.Due)
+		}
+		if (a.Due == nil) != (b.Due == nil) {
+			return a.Due != nil
+		}
+		return a.Id < b.Id
+	})
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Done items can be moved to the archive so they stop cluttering the
+// live list without being thrown away.  An archived item keeps its id,
+// its links and everything else, it is only marked with the time it was
+// archived (ArchivedAt) and left out of listings unless they ask for the
+// archive (see Query.Archived).  Marking an archived item not done brings
+// it back out of the archive.
+//
+// A subtask always goes in and out of the archive along with its parent,
+// so a restored item comes back whole.  Purging removes archived items
+// for good, like DeleteItem does.
+
+// Archive puts a done item in the archive along with its subtasks, which
+// must all be done too.  It returns the ids of the items archived, items
+// that are already archived are left alone.
+func (b *Batch) Archive(id int) ([]int, error) {
+	item, ok := b.items[id]
+	if !ok {
+		return nil, fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+	if !item.IsDone {
+		return nil, mark(fmt.Errorf("item %d is not done, only done items can be archived", id), ErrConflict)
+	}
+
+	tree := subtree(b.items, id)
+	var open []int
+	for _, i := range tree {
+		if !b.items[i].IsDone {
+			open = append(open, i)
+		}
+	}
+	if len(open) > 0 {
+		return nil, &BlockedError{Id: id, Subtasks: open}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	var archived []int
+	for _, i := range tree {
+		old := b.items[i]
+		if old.ArchivedAt != nil {
+			continue
+		}
+
+		item := old
+		stampItem(&item, &old)
+		item.ArchivedAt = &now
+		b.items[i] = item
+		b.record(i, &old, &item)
+		archived = append(archived, i)
+	}
+
+	return archived, nil
+}
+
+// Restore takes an archived item back out of the archive, along with its
+// subtasks and any archived items it is a subtask of.  It returns the ids
+// of the items restored.
+func (b *Batch) Restore(id int) ([]int, error) {
+	item, ok := b.items[id]
+	if !ok {
+		return nil, fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+	if item.ArchivedAt == nil {
+		return nil, mark(fmt.Errorf("item %d is not archived", id), ErrConflict)
+	}
+
+	ids := subtree(b.items, id)
+	for parent := item.ParentId; parent != 0; parent = b.items[parent].ParentId {
+		if b.items[parent].ArchivedAt == nil {
+			break
+		}
+		ids = append(ids, parent)
+	}
+	sort.Ints(ids)
+
+	var restored []int
+	for _, i := range ids {
+		old := b.items[i]
+		if old.ArchivedAt == nil {
+			continue
+		}
+
+		item := old
+		stampItem(&item, &old)
+		item.ArchivedAt = nil
+		b.items[i] = item
+		b.record(i, &old, &item)
+		restored = append(restored, i)
+	}
+
+	return restored, nil
+}
+
+// Purge deletes an archived item and its subtasks for good
+func (b *Batch) Purge(id int) ([]int, error) {
+	item, ok := b.items[id]
+	if !ok {
+		return nil, fmt.Errorf("item %d: %w", id, ErrNotFound)
+	}
+	if item.ArchivedAt == nil {
+		return nil, mark(fmt.Errorf("item %d is not archived, only archived items can be purged", id), ErrConflict)
+	}
+
+	//Subtasks of an archived item are archived as well, delete them
+	//first so they do not become top level items.  A subtask added to
+	//the item after it was archived is not, it is kept.
+	tree := subtree(b.items, id)
+	var purged []int
+	for i := len(tree) - 1; i >= 0; i-- {
+		if b.items[tree[i]].ArchivedAt == nil {
+			continue
+		}
+		if err := b.Delete(tree[i]); err != nil {
+			return nil, err
+		}
+		purged = append(purged, tree[i])
+	}
+	sort.Ints(purged)
+
+	return purged, nil
+}
+
+// ArchiveItems archives the items with the given ids, see Batch.Archive
+func (t *ToDo) ArchiveItems(ids []int) ([]int, error) {
+	return t.archiveEach(OpArchive, ids, (*Batch).Archive)
+}
+
+// RestoreItems takes the items with the given ids out of the archive, see
+// Batch.Restore
+func (t *ToDo) RestoreItems(ids []int) ([]int, error) {
+	return t.archiveEach(OpRestore, ids, (*Batch).Restore)
+}
+
+// PurgeItems deletes the archived items with the given ids, see
+// Batch.Purge
+func (t *ToDo) PurgeItems(ids []int) ([]int, error) {
+	return t.archiveEach(OpPurge, ids, (*Batch).Purge)
+}
+
+// ArchiveCompleted archives every done item that was completed before
+// the given time, or every done item if before is zero.  Items with open
+// subtasks are skipped.  It returns the ids of the items archived.
+func (t *ToDo) ArchiveCompleted(before time.Time) ([]int, error) {
+	var archived []int
+	err := t.batch(context.Background(), OpArchive, func(b *Batch) error {
+		for _, item := range b.Items() {
+			//Look at the item as it is now, it may have been archived
+			//along with its parent already
+			item = b.items[item.Id]
+			if !item.IsDone || item.ArchivedAt != nil || !olderThan(item.CompletedAt, before) {
+				continue
+			}
+
+			ids, err := b.Archive(item.Id)
+			var blocked *BlockedError
+			if errors.As(err, &blocked) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			archived = append(archived, ids...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(archived)
+	return archived, nil
+}
+
+// PurgeArchived deletes every item that was archived before the given
+// time, or every archived item if before is zero.  It returns the ids of
+// the items deleted.
+func (t *ToDo) PurgeArchived(before time.Time) ([]int, error) {
+	var purged []int
+	err := t.batch(context.Background(), OpPurge, func(b *Batch) error {
+		for _, item := range b.Items() {
+			if _, ok := b.items[item.Id]; !ok {
+				//Already gone along with its parent
+				continue
+			}
+			if item.ArchivedAt == nil || !olderThan(item.ArchivedAt, before) {
+				continue
+			}
+
+			ids, err := b.Purge(item.Id)
+			if err != nil {
+				return err
+			}
+			purged = append(purged, ids...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(purged)
+	return purged, nil
+}
+
+// archiveEach runs fn for each id in a single batch and collects the ids
+// it returns
+func (t *ToDo) archiveEach(op JournalOp, ids []int, fn func(b *Batch, id int) ([]int, error)) ([]int, error) {
+	var done []int
+	err := t.batch(context.Background(), op, func(b *Batch) error {
+		for _, id := range ids {
+			changed, err := fn(b, id)
+			if err != nil {
+				return err
+			}
+			done = append(done, changed...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(done)
+	return done, nil
+}
+
+// olderThan reports whether t is before the cutoff, a zero cutoff lets
+// everything through
+func olderThan(t *time.Time, cutoff time.Time) bool {
+	return cutoff.IsZero() || (t != nil && t.Before(cutoff))
+}
+
+// subtree returns id followed by the ids of all of its subtasks, their
+// subtasks and so on, parents before their children
+func subtree(items DbMap, id int) []int {
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, Children(items, ids[i])...)
+	}
+
+	return ids
+}
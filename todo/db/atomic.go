@@ -0,0 +1,55 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic is a crash safe replacement for os.WriteFile.  The data
+// is written and flushed to a temporary file in the same directory, and
+// only then renamed over the real file.  A rename within a directory is
+// atomic, so anyone reading the file sees either the old contents or the
+// new contents, never a half written file.
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(fileName)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(fileName)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	//If anything goes wrong below, don't leave the temp file lying around
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, fileName); err != nil {
+		return err
+	}
+
+	//Flush the directory entry as well so the rename itself survives a
+	//crash.  Not every platform can sync a directory, so this is best
+	//effort only.
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,335 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Batch collects changes to the database so they can be made all at
+// once.  Its methods work like the ToDo methods of the same name and
+// follow the same rules, but only change a working copy of the items.
+// Nothing is saved until the function given to ToDo.Batch returns, and
+// then either every change is saved, in a single write and a single
+// journal entry, or none is.
+//
+// A Batch is only valid inside that function and must not be used from
+// other goroutines.
+type Batch struct {
+	items DbMap
+	meta  Meta
+
+	// op is what the batch is recorded as in the journal.  The single
+	// item functions of ToDo that only know it once they have read the
+	// item change it from within the batch.
+	op JournalOp
+
+	// changes holds a single change per item touched by the batch, in the
+	// order the items were first touched.  index maps item ids to their
+	// position in changes.
+	changes []Change
+	index   map[int]int
+}
+
+// Batch runs fn against a Batch and saves everything it changed at once.
+// The database stays locked while fn runs, so no other process can get
+// in between the changes.  If fn returns an error nothing is saved and
+// the error is returned.
+//
+// One undo reverts the whole batch.
+func (t *ToDo) Batch(fn func(b *Batch) error) error {
+	return t.BatchContext(context.Background(), fn)
+}
+
+// BatchContext is Batch with a context.  If ctx is done before the
+// changes are saved, nothing is saved and ctx.Err() is returned.
+func (t *ToDo) BatchContext(ctx context.Context, fn func(b *Batch) error) error {
+	return t.batch(ctx, OpBatch, fn)
+}
+
+// batch does the work for Batch, recording the changes in the journal as
+// op.  The single item functions of ToDo are batches of one change.
+func (t *ToDo) batch(ctx context.Context, op JournalOp, fn func(b *Batch) error) error {
+	defer t.runHooks()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+
+	b := &Batch{
+		items: make(DbMap, len(t.toDoMap)),
+		meta:  t.meta,
+		op:    op,
+		index: make(map[int]int),
+	}
+	for id, item := range t.toDoMap {
+		b.items[id] = item
+	}
+
+	if err := fn(b); err != nil {
+		return err
+	}
+
+	//An item added and deleted again within the batch leaves no trace
+	var changes []Change
+	for _, change := range b.changes {
+		if change.Before != nil || change.After != nil {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	//Last chance to give up, once we commit the changes are saved
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return t.commit(b.meta, JournalEntry{Op: b.op, Changes: changes})
+}
+
+// Get returns an item as it stands in the batch
+func (b *Batch) Get(id int) (ToDoItem, error) {
+	if item, ok := b.items[id]; ok {
+		return item, nil
+	}
+
+	return ToDoItem{}, ErrNotFound
+}
+
+// Items returns every item as it stands in the batch, sorted by id
+func (b *Batch) Items() []ToDoItem {
+	items := make([]ToDoItem, 0, len(b.items))
+	for _, item := range b.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+
+	return items
+}
+
+// Add adds an item under a new id and returns the id, see ToDo.AddItem
+func (b *Batch) Add(item ToDoItem) (int, error) {
+	item.Id = nextId(b.meta.Seq, b.items)
+	stampItem(&item, nil)
+	cleanBlockedBy(&item)
+	if item.List == "" {
+		item.List = DefaultList
+	}
+
+	if err := checkListName(item.List); err != nil {
+		return 0, invalidItem(err)
+	}
+	if err := item.Recur.check(); err != nil {
+		return 0, invalidItem(err)
+	}
+
+	b.items[item.Id] = item
+	err := invalidItem(checkLinks(b.items, []ToDoItem{item}))
+	if err == nil && item.IsDone {
+		err = checkBlocked(b.items, item)
+	}
+	if err != nil {
+		delete(b.items, item.Id)
+		return 0, err
+	}
+
+	b.meta.Seq = item.Id
+	b.record(item.Id, nil, &item)
+	return item.Id, nil
+}
+
+// Update replaces an item, see ToDo.UpdateItem
+func (b *Batch) Update(item ToDoItem) error {
+	return b.update(item, false)
+}
+
+// SetDone changes the done status of an item, see
+// ToDo.ChangeItemDoneStatus
+func (b *Batch) SetDone(id int, value bool) error {
+	item, err := b.Get(id)
+	if err != nil {
+		return err
+	}
+
+	item.IsDone = value
+	return b.update(item, false)
+}
+
+// ForceDone marks an item done even if items it depends on are still
+// open, see ToDo.ForceItemDone
+func (b *Batch) ForceDone(id int) error {
+	item, err := b.Get(id)
+	if err != nil {
+		return err
+	}
+
+	item.IsDone = true
+	return b.update(item, true)
+}
+
+// update replaces an item, force allows it to be marked done while it is
+// blocked
+func (b *Batch) update(item ToDoItem, force bool) error {
+	old, ok := b.items[item.Id]
+	if !ok {
+		return fmt.Errorf("%w --- cannot update", ErrNotFound)
+	}
+
+	stampItem(&item, &old)
+	cleanBlockedBy(&item)
+	if item.List == "" {
+		item.List = old.List
+	}
+
+	if err := checkListName(item.List); err != nil {
+		return invalidItem(err)
+	}
+	if err := item.Recur.check(); err != nil {
+		return invalidItem(err)
+	}
+
+	b.items[item.Id] = item
+	err := invalidItem(checkLinks(b.items, []ToDoItem{item}))
+	if err == nil && item.IsDone && !old.IsDone && !force {
+		err = checkBlocked(b.items, item)
+	}
+	if err != nil {
+		b.items[item.Id] = old
+		return err
+	}
+
+	//Completing a recurring item adds its next occurrence, which takes
+	//the rule over from it
+	var next *ToDoItem
+	if item.IsDone && !old.IsDone && item.Recur != nil {
+		next = nextOccurrence(item, nextId(b.meta.Seq, b.items), item.UpdatedAt)
+		item.Recur = nil
+		b.items[item.Id] = item
+	}
+
+	b.record(item.Id, &old, &item)
+	if next != nil {
+		b.items[next.Id] = *next
+		b.meta.Seq = next.Id
+		b.record(next.Id, nil, next)
+	}
+
+	return nil
+}
+
+// Delete removes an item, see ToDo.DeleteItem
+func (b *Batch) Delete(id int) error {
+	old, ok := b.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	unlinks := unlinkChanges(b.items, id)
+
+	delete(b.items, id)
+	b.record(id, &old, nil)
+	for _, change := range unlinks {
+		b.items[change.Id] = *change.After
+		b.record(change.Id, change.Before, change.After)
+	}
+
+	return nil
+}
+
+// Move puts items and their subtasks on another list and returns the ids
+// of the items that moved, see ToDo.MoveItems
+func (b *Batch) Move(ids []int, list string) ([]int, error) {
+	list, err := ParseListName(list)
+	if err != nil {
+		return nil, invalidItem(err)
+	}
+
+	for _, id := range ids {
+		if _, ok := b.items[id]; !ok {
+			return nil, fmt.Errorf("item %d: %w", id, ErrNotFound)
+		}
+	}
+
+	//Collect the items along with all of their subtasks
+	seen := make(map[int]bool)
+	var moving []int
+	var collect func(id int)
+	collect = func(id int) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		moving = append(moving, id)
+		for _, child := range Children(b.items, id) {
+			collect(child)
+		}
+	}
+	for _, id := range ids {
+		collect(id)
+	}
+
+	var moved []int
+	for _, id := range moving {
+		old := b.items[id]
+		if old.List == list {
+			continue
+		}
+
+		item := old
+		item.List = list
+		stampItem(&item, &old)
+		b.items[id] = item
+		b.record(id, &old, &item)
+		moved = append(moved, id)
+	}
+
+	return moved, nil
+}
+
+// put stores item as it is, without any of the checks or timestamps of
+// Update, for changes that were worked out and checked elsewhere
+func (b *Batch) put(item ToDoItem) {
+	var before *ToDoItem
+	if old, ok := b.items[item.Id]; ok {
+		before = &old
+	}
+
+	b.items[item.Id] = item
+	b.record(item.Id, before, &item)
+}
+
+// remove deletes an item without unlinking the items that point at it,
+// see put
+func (b *Batch) remove(id int) {
+	old, ok := b.items[id]
+	if !ok {
+		return
+	}
+
+	delete(b.items, id)
+	b.record(id, &old, nil)
+}
+
+// record notes a change to an item.  An item changed several times keeps
+// the image from before its first change and the one after its last.
+func (b *Batch) record(id int, before, after *ToDoItem) {
+	if i, ok := b.index[id]; ok {
+		b.changes[i].After = after
+		return
+	}
+
+	b.index[id] = len(b.changes)
+	b.changes = append(b.changes, Change{Id: id, Before: before, After: after})
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// A batch that fails saves none of its changes, not even the ones made
+// before the failing one
+func TestBatchRollback(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "keep", "drop")
+
+	errStop := errors.New("stop")
+	err := todo.Batch(func(b *Batch) error {
+		if _, err := b.Add(ToDoItem{Title: "added"}); err != nil {
+			return err
+		}
+		if err := b.Update(ToDoItem{Id: ids[0], Title: "renamed"}); err != nil {
+			return err
+		}
+		if err := b.Delete(ids[1]); err != nil {
+			return err
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("got %v, want the error of the batch", err)
+	}
+
+	//Check the file rather than what the ToDo has in memory
+	after := openDB(t, todo.dbFileName)
+	items, err := after.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Title != "keep" || items[1].Title != "drop" {
+		t.Errorf("got %v, want the items from before the batch", items)
+	}
+	history, err := after.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("got %d journal entries, want only the 2 adds", len(history))
+	}
+
+	//The id the failed batch took is handed out again
+	id, err := todo.AddItem(ToDoItem{Title: "next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Errorf("got id %d, want 3", id)
+	}
+}
+
+// An item refused by the batch fails the whole batch
+func TestBatchRollbackInvalidItem(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "first")
+
+	err := todo.Batch(func(b *Batch) error {
+		if err := b.Update(ToDoItem{Id: ids[0], Title: "renamed"}); err != nil {
+			return err
+		}
+		_, err := b.Add(ToDoItem{Title: "orphan", ParentId: 99})
+		return err
+	})
+	if !errors.Is(err, ErrInvalidItem) {
+		t.Fatalf("got %v, want ErrInvalidItem", err)
+	}
+
+	item, err := todo.GetItem(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Title != "first" {
+		t.Errorf("got title %q, want the one from before the batch", item.Title)
+	}
+}
+
+// A batch that works is saved as one journal entry, and one undo takes
+// all of it back
+func TestBatchUndo(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "a", "b")
+
+	err := todo.Batch(func(b *Batch) error {
+		if err := b.SetDone(ids[0], true); err != nil {
+			return err
+		}
+		if err := b.Delete(ids[1]); err != nil {
+			return err
+		}
+		_, err := b.Add(ToDoItem{Title: "c"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := todo.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Changes) != 3 {
+		t.Errorf("undo reverted %d changes, want 3", len(entry.Changes))
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].IsDone || items[1].Title != "b" {
+		t.Errorf("got %v, want a and b as they were before the batch", items)
+	}
+}
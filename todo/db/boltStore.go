@@ -0,0 +1,326 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// itemsBucket is the bolt bucket that holds one record per ToDoItem,
+// metaBucket holds the database wide values from Meta
+var (
+	itemsBucket = []byte("items")
+	metaBucket  = []byte("meta")
+	seqKey      = []byte("seq")
+	versionKey  = []byte("version")
+)
+
+// boltOpenTimeout is how long we wait for another process to let go of
+// the bolt file before giving up
+const boltOpenTimeout = 5 * time.Second
+
+// boltStore keeps the items in an embedded key-value file (bbolt).
+// Each item is its own record keyed by id, so adding or updating an
+// item only writes that item instead of the whole database.
+//
+// bolt holds an exclusive lock on the file while it is open, so the
+// file is opened for each operation rather than for the lifetime of
+// the store.  That lets several todo processes share the same file.
+type boltStore struct {
+	dbFileName string
+}
+
+func newBoltStore(dbFileName string) (*boltStore, error) {
+	s := &boltStore{dbFileName: dbFileName}
+
+	//Opening the file creates it if it does not exist, make sure our
+	//buckets are there too so reads never have to check for them, and
+	//bring databases written by older code up to the current schema
+	err := s.update(func(tx *bolt.Tx) error {
+		isNew := tx.Bucket(itemsBucket) == nil
+
+		if _, err := tx.CreateBucketIfNotExists(itemsBucket); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		//Bolt databases were only ever written with the id sequence in
+		//place, so one without a version is at version 1
+		version := SchemaVersion
+		if v := meta.Get(versionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		} else if !isNew {
+			version = 1
+		}
+
+		if version != SchemaVersion {
+			if err := s.migrate(tx, version); err != nil {
+				return err
+			}
+		}
+
+		return meta.Put(versionKey, boltKey(SchemaVersion))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *boltStore) Load() (DbMap, Meta, error) {
+	items := make(DbMap)
+	var meta Meta
+
+	err := s.view(func(tx *bolt.Tx) error {
+		meta.Rev = uint64(tx.ID())
+		if seq := tx.Bucket(metaBucket).Get(seqKey); seq != nil {
+			meta.Seq = int(binary.BigEndian.Uint64(seq))
+		}
+
+		return tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+			item, err := s.decodeItem(k, v)
+			if err != nil {
+				return err
+			}
+			items[item.Id] = item
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	return items, meta, nil
+}
+
+func (s *boltStore) Save(meta Meta, puts []ToDoItem, deletes []int) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(metaBucket).Put(seqKey, boltKey(meta.Seq)); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(itemsBucket)
+
+		for _, id := range deletes {
+			if err := b.Delete(boltKey(id)); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range puts {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(boltKey(item.Id), data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// rev is the id of the last write transaction, bolt counts them up on
+// every commit whoever makes it
+func (s *boltStore) rev() (uint64, error) {
+	var rev uint64
+	err := s.view(func(tx *bolt.Tx) error {
+		rev = uint64(tx.ID())
+		return nil
+	})
+	return rev, err
+}
+
+// migrate upgrades every item in the database from the given schema
+// version.  Unlike the JSON file, records that are not touched by a save
+// are never rewritten, so the upgrade has to be done up front.
+func (s *boltStore) migrate(tx *bolt.Tx, version int) error {
+	info, err := os.Stat(s.dbFileName)
+	if err != nil {
+		return err
+	}
+
+	var items []ToDoItem
+	b := tx.Bucket(itemsBucket)
+	err = b.ForEach(func(k, v []byte) error {
+		item, err := s.decodeItem(k, v)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := migrate(version, items, info.ModTime()); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(boltKey(item.Id), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *boltStore) Close() error {
+	return nil
+}
+
+// decodeItem reads the record stored under key k, which must be the
+// item's own id
+func (s *boltStore) decodeItem(k, v []byte) (ToDoItem, error) {
+	var item ToDoItem
+	if len(k) != 8 {
+		return item, fmt.Errorf("%w: %s: record with a malformed key %x", ErrCorruptDB, s.dbFileName, k)
+	}
+
+	key := binary.BigEndian.Uint64(k)
+	if err := json.Unmarshal(v, &item); err != nil {
+		return item, fmt.Errorf("%w: %s: record %d: %w", ErrCorruptDB, s.dbFileName, key, err)
+	}
+	if key != uint64(item.Id) {
+		return item, fmt.Errorf("%w: %s: item %d is stored under key %d", ErrCorruptDB, s.dbFileName, item.Id, key)
+	}
+
+	return item, nil
+}
+
+func (s *boltStore) open() (db *bolt.DB, err error) {
+	//bolt panics rather than returning an error on some kinds of damage
+	//to its pages
+	defer func() {
+		if p := recover(); p != nil {
+			db, err = nil, fmt.Errorf("%w: %s: %v", ErrCorruptDB, s.dbFileName, p)
+		}
+	}()
+
+	db, err = bolt.Open(s.dbFileName, 0644, &bolt.Options{Timeout: boltOpenTimeout})
+	if errors.Is(err, bolt.ErrInvalid) || errors.Is(err, bolt.ErrChecksum) || errors.Is(err, bolt.ErrVersionMismatch) {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCorruptDB, s.dbFileName, err)
+	}
+
+	return db, err
+}
+
+func (s *boltStore) view(fn func(*bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+func (s *boltStore) update(fn func(*bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(fn)
+}
+
+// boltKey encodes an item id as a big endian key so that bolt keeps
+// the records sorted by id.  The id sequence is stored the same way.
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func (s *boltStore) scan() (fsckScan, error) {
+	var scan fsckScan
+
+	info, err := os.Stat(s.dbFileName)
+	if err != nil {
+		return scan, err
+	}
+	scan.fileTime = info.ModTime()
+
+	//The store migrates the records when it is opened, so they are at
+	//the current version by now
+	scan.version = SchemaVersion
+
+	err = s.view(func(tx *bolt.Tx) error {
+		//bolt's own check of its pages, damage at that level is beyond
+		//what Repair can fix
+		for err := range tx.Check() {
+			scan.problems = append(scan.problems, Problem{Message: err.Error()})
+		}
+
+		if seq := tx.Bucket(metaBucket).Get(seqKey); seq != nil {
+			scan.meta.Seq = int(binary.BigEndian.Uint64(seq))
+		}
+
+		return tx.Bucket(itemsBucket).ForEach(func(k, v []byte) error {
+			var pos Position
+			if len(k) == 8 {
+				pos.Record = int(binary.BigEndian.Uint64(k))
+			}
+
+			var item ToDoItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				scan.problems = append(scan.problems, Problem{Pos: pos, Message: "cannot read item: " + err.Error(), Fix: "drop it"})
+				return nil
+			}
+			if pos.Record != item.Id {
+				scan.problems = append(scan.problems, Problem{
+					Pos:     pos,
+					Id:      item.Id,
+					Message: "item is stored under the wrong key",
+					Fix:     "store it under its id",
+				})
+			}
+
+			scan.items = append(scan.items, scannedItem{item, pos})
+			return nil
+		})
+	})
+
+	return scan, err
+}
+
+func (s *boltStore) replace(meta Meta, items []ToDoItem) error {
+	return s.update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(itemsBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(itemsBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(boltKey(item.Id), data); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(metaBucket).Put(seqKey, boltKey(meta.Seq))
+	})
+}
@@ -0,0 +1,451 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// The JSON file backend can keep the database encrypted at rest.  This is
+// opt-in: a database stays plain text until Encrypt is called on it, and
+// from then on every write keeps it encrypted until Decrypt is called.
+//
+// An encrypted database is still a JSON object, but the items are replaced
+// by the whole plain text file sealed with AES-256-GCM:
+//
+//	{
+//	  "version": 5,
+//	  "encryption": { "kdf": "scrypt", "n": 32768, "r": 8, "p": 1, "salt": "..." },
+//	  "nonce": "...",
+//	  "data": "..."
+//	}
+//
+// The key is derived from a passphrase with scrypt, using the parameters
+// and the random salt recorded in the file.  The encryption header is
+// authenticated along with the data, so it cannot be tampered with
+// without the file failing to open.  The version is there so that todo
+// from before encryption refuses the file instead of taking it for an
+// empty database.
+//
+// The journal next to the database holds copies of the items too, so its
+// lines are sealed with the same key.  An encrypted line is the nonce
+// followed by the ciphertext, in base64.  Encrypt seals the lines written
+// before it, after that a plain text line in the journal is not trusted:
+// anyone who can write the file could have put it there.
+
+// The scrypt parameters used for new files, as recommended by the scrypt
+// package for interactive logins in 2017
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltLen = 16
+	keyLen  = 32
+)
+
+// kdfParams records how the key of an encrypted database is derived from
+// its passphrase
+type kdfParams struct {
+	KDF  string `json:"kdf"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+}
+
+// encryptedFile is the layout of an encrypted database file on disk
+type encryptedFile struct {
+	Version    int       `json:"version"`
+	Encryption kdfParams `json:"encryption"`
+	Nonce      []byte    `json:"nonce"`
+	Data       []byte    `json:"data"`
+}
+
+// sealer encrypts and decrypts with the key derived from a passphrase
+type sealer struct {
+	params kdfParams
+	aead   cipher.AEAD
+}
+
+// newSealer derives a key from passphrase with a fresh random salt, for
+// encrypting a database for the first time or under a new passphrase
+func newSealer(passphrase []byte) (*sealer, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("the passphrase cannot be empty")
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return deriveSealer(passphrase, kdfParams{KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP, Salt: salt})
+}
+
+// deriveSealer derives the key of an existing encrypted database from
+// passphrase
+func deriveSealer(passphrase []byte, params kdfParams) (*sealer, error) {
+	if params.KDF != "scrypt" {
+		return nil, fmt.Errorf("%w: unknown key derivation function %q", ErrCorruptDB, params.KDF)
+	}
+
+	key, err := scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptDB, err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sealer{params: params, aead: aead}, nil
+}
+
+// sameKey reports whether s uses the key described by params
+func (s *sealer) sameKey(params kdfParams) bool {
+	return s.params.KDF == params.KDF && s.params.N == params.N && s.params.R == params.R &&
+		s.params.P == params.P && bytes.Equal(s.params.Salt, params.Salt)
+}
+
+// header is the additional data authenticated along with the file
+func (s *sealer) header() ([]byte, error) {
+	return json.Marshal(s.params)
+}
+
+func (s *sealer) nonce() ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	return nonce, err
+}
+
+// sealFile encrypts the content of a plain database file
+func (s *sealer) sealFile(plain []byte) ([]byte, error) {
+	header, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := s.nonce()
+	if err != nil {
+		return nil, err
+	}
+
+	file := encryptedFile{
+		Version:    SchemaVersion,
+		Encryption: s.params,
+		Nonce:      nonce,
+		Data:       s.aead.Seal(nil, nonce, plain, header),
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// openFile decrypts an encrypted database file back to its plain content
+func (s *sealer) openFile(file encryptedFile) ([]byte, error) {
+	header, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != s.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: bad nonce", ErrCorruptDB)
+	}
+
+	//GCM cannot tell a wrong key from a damaged file, a wrong passphrase
+	//is by far the likelier of the two
+	plain, err := s.aead.Open(nil, file.Nonce, file.Data, header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plain, nil
+}
+
+// sealLine encrypts one line of the journal, without its newline
+func (s *sealer) sealLine(line []byte) ([]byte, error) {
+	nonce, err := s.nonce()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, line, nil)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
+
+// openLine decrypts a line of the journal written by sealLine
+func (s *sealer) openLine(line []byte) ([]byte, error) {
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, err := base64.StdEncoding.Decode(sealed, line)
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[:n]
+
+	size := s.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("%w: journal line too short", ErrCorruptDB)
+	}
+	return s.aead.Open(nil, sealed[:size], sealed[size:], nil)
+}
+
+// parseEncrypted returns the envelope of an encrypted database file, ok
+// is false if data is a plain database
+func parseEncrypted(data []byte) (file encryptedFile, ok bool) {
+	if !bytes.Contains(data, []byte(`"encryption"`)) {
+		return file, false
+	}
+	if json.Unmarshal(data, &file) != nil {
+		return file, false
+	}
+
+	return file, file.Encryption.KDF != ""
+}
+
+// cryptStore is implemented by stores that can keep their file encrypted
+type cryptStore interface {
+	// setPassphrase sets the function asked for the passphrase the first
+	// time an encrypted file has to be opened
+	setPassphrase(passphrase func() (string, error))
+
+	// encrypted reports whether the file is encrypted, loading it if it
+	// was not loaded yet
+	encrypted() (bool, error)
+
+	// sealer is the sealer the file was last read or written with, nil
+	// if it is not encrypted
+	sealer() *sealer
+
+	// reseal writes the file again encrypted with s, or in plain text if
+	// s is nil
+	reseal(s *sealer) error
+}
+
+// SetPassphrase sets the passphrase that opens an encrypted database.  It
+// is not needed for a database that is not encrypted.
+func (t *ToDo) SetPassphrase(passphrase string) {
+	t.SetPassphraseFunc(func() (string, error) {
+		return passphrase, nil
+	})
+}
+
+// SetPassphraseFunc sets a function that is asked for the passphrase the
+// first time the database turns out to be encrypted, for example to
+// prompt the user only when there is a need to.  The answer is
+// remembered.  Without a passphrase, opening an encrypted database fails
+// with ErrPassphraseRequired.
+func (t *ToDo) SetPassphraseFunc(passphrase func() (string, error)) {
+	if store, ok := t.store.(cryptStore); ok {
+		store.setPassphrase(passphrase)
+	}
+}
+
+// IsEncrypted reports whether the database is encrypted
+func (t *ToDo) IsEncrypted() (bool, error) {
+	store, ok := t.store.(cryptStore)
+	if !ok {
+		return false, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return store.encrypted()
+}
+
+// Encrypt encrypts a plain text database, and its journal, with a key
+// derived from passphrase.  From then on the passphrase is needed to open
+// the database, see SetPassphrase.  Only the JSON file backend can be
+// encrypted.
+func (t *ToDo) Encrypt(passphrase string) error {
+	return t.reseal(func(encrypted bool) (*sealer, error) {
+		if encrypted {
+			return nil, mark(errors.New("the database is already encrypted, use Rekey to change the passphrase"), ErrConflict)
+		}
+		return newSealer([]byte(passphrase))
+	})
+}
+
+// Rekey encrypts an encrypted database, and its journal, again with a key
+// derived from a new passphrase.  The old passphrase must have been set
+// to open it.
+func (t *ToDo) Rekey(newPassphrase string) error {
+	return t.reseal(func(encrypted bool) (*sealer, error) {
+		if !encrypted {
+			return nil, mark(errors.New("the database is not encrypted, use Encrypt to encrypt it"), ErrConflict)
+		}
+		return newSealer([]byte(newPassphrase))
+	})
+}
+
+// Decrypt turns an encrypted database, and its journal, back into plain
+// text.  The passphrase must have been set to open it.
+func (t *ToDo) Decrypt() error {
+	return t.reseal(func(encrypted bool) (*sealer, error) {
+		if !encrypted {
+			return nil, mark(errors.New("the database is not encrypted"), ErrConflict)
+		}
+		return nil, nil
+	})
+}
+
+// reseal rewrites the database, its journal and its sync bases with the
+// sealer returned by next, which is told whether the database is
+// encrypted now
+func (t *ToDo) reseal(next func(encrypted bool) (*sealer, error)) error {
+	store, ok := t.store.(cryptStore)
+	if !ok {
+		return mark(errors.New("encryption is only supported by the JSON file backend"), ErrNotSupported)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	encrypted, err := store.encrypted()
+	if err != nil {
+		return err
+	}
+	old := store.sealer()
+
+	s, err := next(encrypted)
+	if err != nil {
+		return err
+	}
+
+	//Seal the journal first, if that fails the database is untouched, and
+	//if writing the database fails after it the old lines can still be
+	//read with the passphrase that still opens the database
+	journal, err := t.resealJournal(old, s)
+	if err != nil {
+		return err
+	}
+	if err := store.reseal(s); err != nil {
+		return err
+	}
+	if journal != nil {
+		if err := writeFileAtomic(t.journalFileName(), journal, journalPerm(s)); err != nil {
+			return fmt.Errorf("the database was written but its journal could not be: %w", err)
+		}
+	}
+	if err := t.resealSyncBases(old, s); err != nil {
+		return fmt.Errorf("the database was written but its sync bases could not be: %w", err)
+	}
+
+	//The file changed under our stamp, load it again next time
+	t.stamp = nil
+
+	return nil
+}
+
+// resealJournal returns the journal with every line sealed with next
+// instead of old, nil for either means plain text.  Lines that cannot be
+// read are kept as they are, so the numbering of the entries does not
+// change.  A missing journal gives nil.
+func (t *ToDo) resealJournal(old, next *sealer) ([]byte, error) {
+	data, err := os.ReadFile(t.journalFileName())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for _, line := range lines {
+		text := bytes.TrimSpace(line)
+		if len(text) == 0 {
+			out.Write(line)
+			continue
+		}
+
+		plain, err := openJournalLine(old, text)
+		if errors.Is(err, errPlainJournalLine) {
+			//Blank it out, or Decrypt would make it trusted
+			out.WriteByte('\n')
+			continue
+		}
+		if err != nil {
+			out.Write(line)
+			continue
+		}
+		sealed, err := sealJournalLine(next, plain)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(sealed)
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes(), nil
+}
+
+// sealJournalLine seals one journal line with s, nil leaves it plain
+func sealJournalLine(s *sealer, line []byte) ([]byte, error) {
+	if s == nil {
+		return line, nil
+	}
+
+	return s.sealLine(line)
+}
+
+// errPlainJournalLine is returned for a plain text line in the journal of
+// an encrypted database
+var errPlainJournalLine = fmt.Errorf("%w: plain text line in an encrypted journal", ErrCorruptDB)
+
+// openJournalLine reads one journal line, sealed with s or in plain text
+// if s is nil.  Plain lines are JSON objects, which base64 never starts
+// with.  A plain line is refused when s is set, as it is not
+// authenticated.
+func openJournalLine(s *sealer, line []byte) ([]byte, error) {
+	plain := bytes.HasPrefix(line, []byte("{"))
+	switch {
+	case plain && s == nil:
+		return line, nil
+	case plain:
+		return nil, errPlainJournalLine
+	case s == nil:
+		return nil, fmt.Errorf("%w: encrypted line in a plain text journal", ErrCorruptDB)
+	}
+
+	return s.openLine(line)
+}
+
+// journalPerm is the mode of a journal file, an encrypted journal is
+// kept private like the database
+func journalPerm(s *sealer) os.FileMode {
+	if s != nil {
+		return 0600
+	}
+
+	return 0644
+}
+
+// journalSealer returns the sealer journal lines are written with, nil
+// if the database is not encrypted
+func (t *ToDo) journalSealer() *sealer {
+	if store, ok := t.store.(cryptStore); ok {
+		return store.sealer()
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileHas reports whether the file holds text
+func fileHas(t *testing.T, fileName, text string) bool {
+	t.Helper()
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Contains(data, []byte(text))
+}
+
+// titles opens the database with passphrase, an empty one for none, and
+// returns the titles of its items
+func titles(t *testing.T, dbFile, passphrase string) ([]string, error) {
+	t.Helper()
+
+	todo := openDB(t, dbFile)
+	if passphrase != "" {
+		todo.SetPassphrase(passphrase)
+	}
+	items, err := todo.GetAllItems()
+	var titles []string
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles, err
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	todo := openTestDB(t)
+	dbFile := todo.dbFileName
+	addItems(t, todo, "secret plans")
+
+	if err := todo.Encrypt("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.Encrypt("again"); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v encrypting twice, want ErrConflict", err)
+	}
+	if fileHas(t, dbFile, "secret plans") || fileHas(t, dbFile+".journal", "secret plans") {
+		t.Fatal("the title is still readable in the database or its journal")
+	}
+
+	if _, err := titles(t, dbFile, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("got %v without a passphrase, want ErrPassphraseRequired", err)
+	}
+	if _, err := titles(t, dbFile, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("got %v with the wrong passphrase, want ErrWrongPassphrase", err)
+	}
+	if got, err := titles(t, dbFile, "first"); err != nil || len(got) != 1 || got[0] != "secret plans" {
+		t.Errorf("got %v, %v with the passphrase, want the item", got, err)
+	}
+
+	if err := todo.Rekey("second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := titles(t, dbFile, "first"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("got %v with the old passphrase, want ErrWrongPassphrase", err)
+	}
+	if _, err := titles(t, dbFile, "second"); err != nil {
+		t.Errorf("got %v with the new passphrase", err)
+	}
+
+	//The journal was sealed again with the new key, so undo still works
+	reopened := openDB(t, dbFile)
+	reopened.SetPassphrase("second")
+	if _, err := reopened.AddItem(ToDoItem{Title: "more plans"}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := reopened.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("got %d journal entries, want 2", len(history))
+	}
+
+	if err := reopened.Decrypt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Decrypt(); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v decrypting twice, want ErrConflict", err)
+	}
+	if !fileHas(t, dbFile, "secret plans") || !fileHas(t, dbFile+".journal", "more plans") {
+		t.Error("the database or its journal is not plain text after Decrypt")
+	}
+	if got, err := titles(t, dbFile, ""); err != nil || len(got) != 2 {
+		t.Errorf("got %v, %v without a passphrase, want both items", got, err)
+	}
+}
+
+// Lines in the journal of an encrypted database have to be sealed, a
+// plain one could have been put there by anyone
+func TestEncryptedJournalRefusesPlainLines(t *testing.T) {
+	todo := openTestDB(t)
+	todo.SetPassphrase("secret")
+	addItems(t, todo, "a")
+	if err := todo.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(todo.journalFileName(), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`{"time":"2020-01-01T00:00:00Z","op":"delete","changes":[{"id":1,"before":{"id":1,"title":"a"}}]}` + "\n")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := todo.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Op != OpAdd {
+		t.Errorf("got %v, want only the add", history)
+	}
+}
+
+func TestEncryptBoltNotSupported(t *testing.T) {
+	todo := openDB(t, "bolt://"+filepath.Join(t.TempDir(), "todo.db"))
+	if err := todo.Encrypt("secret"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the formats accepted by ParseDate
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	time.RFC3339,
+}
+
+// ParseDate reads a date typed in by a user, on the command line or in
+// an API query string.  Dates without a time zone are in local time.  An
+// empty string means no date.
+func ParseDate(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	switch strings.ToLower(s) {
+	case "":
+		return nil, nil
+	case "today":
+		return &today, nil
+	case "tomorrow":
+		tomorrow := today.AddDate(0, 0, 1)
+		return &tomorrow, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid date %q, use YYYY-MM-DD or YYYY-MM-DD HH:MM", s)
+}
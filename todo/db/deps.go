@@ -0,0 +1,240 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Items can be linked in two ways.  An item with a ParentId is a subtask
+// of that parent, and an item with BlockedBy ids cannot be finished
+// before those items are.  Either way the item depends on the other ones:
+// a parent depends on its subtasks, a blocked item on its blockers.  The
+// db package keeps these links pointing at items that exist, refuses
+// links that would make an item (indirectly) depend on itself, and
+// refuses to mark an item done while anything it depends on is still
+// open.
+
+// BlockedError is returned when an item cannot be marked done because
+// items it depends on are still open
+type BlockedError struct {
+	Id int
+
+	// Blockers are the open items in the item's BlockedBy list, and
+	// Subtasks its open children
+	Blockers []int
+	Subtasks []int
+}
+
+func (e *BlockedError) Error() string {
+	var reasons []string
+	if len(e.Blockers) > 0 {
+		reasons = append(reasons, "is blocked by open "+plural("item", e.Blockers))
+	}
+	if len(e.Subtasks) > 0 {
+		reasons = append(reasons, "has open "+plural("subtask", e.Subtasks))
+	}
+
+	return fmt.Sprintf("item %d %s", e.Id, strings.Join(reasons, " and "))
+}
+
+func (e *BlockedError) Unwrap() error { return ErrBlocked }
+
+// plural writes "item 3" or "items 3, 4"
+func plural(noun string, ids []int) string {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	if len(ids) != 1 {
+		noun += "s"
+	}
+
+	return noun + " " + strings.Join(list, ", ")
+}
+
+// Children returns the ids of the subtasks of an item, in id order
+func Children(items DbMap, id int) []int {
+	var children []int
+	for childId, item := range items {
+		if item.ParentId == id {
+			children = append(children, childId)
+		}
+	}
+	sort.Ints(children)
+
+	return children
+}
+
+// checkBlocked returns a BlockedError if item depends on items that are
+// not done yet
+func checkBlocked(items DbMap, item ToDoItem) error {
+	blocked := &BlockedError{Id: item.Id}
+
+	for _, id := range item.BlockedBy {
+		if blocker, ok := items[id]; ok && !blocker.IsDone {
+			blocked.Blockers = append(blocked.Blockers, id)
+		}
+	}
+	for _, id := range Children(items, item.Id) {
+		if !items[id].IsDone {
+			blocked.Subtasks = append(blocked.Subtasks, id)
+		}
+	}
+
+	if len(blocked.Blockers) > 0 || len(blocked.Subtasks) > 0 {
+		return blocked
+	}
+
+	return nil
+}
+
+// checkLinks makes sure the parent and blockers of each of the changed
+// items exist, and that the links do not form a cycle.  items must
+// already hold the changed items.
+func checkLinks(items DbMap, changed []ToDoItem) error {
+	for _, item := range changed {
+		if item.ParentId != 0 {
+			if item.ParentId == item.Id {
+				return fmt.Errorf("item %d cannot be its own parent", item.Id)
+			}
+			if _, ok := items[item.ParentId]; !ok {
+				return fmt.Errorf("parent item %d of item %d does not exist", item.ParentId, item.Id)
+			}
+		}
+
+		for _, id := range item.BlockedBy {
+			if id == item.Id {
+				return fmt.Errorf("item %d cannot block itself", item.Id)
+			}
+			if _, ok := items[id]; !ok {
+				return fmt.Errorf("blocking item %d of item %d does not exist", id, item.Id)
+			}
+		}
+	}
+
+	for _, item := range changed {
+		if cycle := findCycle(items, item.Id); cycle != nil {
+			return fmt.Errorf("item %d would end up depending on itself: %s", item.Id, formatCycle(cycle))
+		}
+	}
+
+	return nil
+}
+
+// findCycle looks for a chain of dependencies that leads from start back
+// to start, and returns it if there is one
+func findCycle(items DbMap, start int) []int {
+	//Each item depends on its blockers and on its subtasks
+	dependsOn := make(map[int][]int, len(items))
+	for id, item := range items {
+		dependsOn[id] = append(dependsOn[id], item.BlockedBy...)
+		if item.ParentId != 0 {
+			dependsOn[item.ParentId] = append(dependsOn[item.ParentId], id)
+		}
+	}
+
+	visited := make(map[int]bool)
+	var path []int
+
+	var visit func(id int) bool
+	visit = func(id int) bool {
+		path = append(path, id)
+		for _, next := range dependsOn[id] {
+			if next == start {
+				path = append(path, next)
+				return true
+			}
+			if !visited[next] {
+				visited[next] = true
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(start) {
+		return path
+	}
+
+	return nil
+}
+
+func formatCycle(cycle []int) string {
+	list := make([]string, len(cycle))
+	for i, id := range cycle {
+		list[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(list, " -> ")
+}
+
+// unlinkChanges works out the changes needed to drop every link to the
+// item being deleted: its subtasks become top level items and it is taken
+// out of the BlockedBy lists of the items it blocked
+func unlinkChanges(items DbMap, deletedId int) []Change {
+	var changes []Change
+
+	ids := make([]int, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		old := items[id]
+		if id == deletedId {
+			continue
+		}
+
+		item := old
+		item.BlockedBy = removeId(old.BlockedBy, deletedId)
+		if item.ParentId == deletedId {
+			item.ParentId = 0
+		}
+		if item.ParentId == old.ParentId && len(item.BlockedBy) == len(old.BlockedBy) {
+			continue
+		}
+
+		stampItem(&item, &old)
+		before, after := old, item
+		changes = append(changes, Change{Id: id, Before: &before, After: &after})
+	}
+
+	return changes
+}
+
+// removeId returns ids without id, or nil if nothing is left
+func removeId(ids []int, id int) []int {
+	var kept []int
+	for _, i := range ids {
+		if i != id {
+			kept = append(kept, i)
+		}
+	}
+
+	return kept
+}
+
+// cleanBlockedBy sorts the BlockedBy list and drops duplicates
+func cleanBlockedBy(item *ToDoItem) {
+	if len(item.BlockedBy) == 0 {
+		item.BlockedBy = nil
+		return
+	}
+
+	sorted := append([]int(nil), item.BlockedBy...)
+	sort.Ints(sorted)
+
+	ids := sorted[:1]
+	for _, id := range sorted[1:] {
+		if id != ids[len(ids)-1] {
+			ids = append(ids, id)
+		}
+	}
+	item.BlockedBy = ids
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		links map[int]ToDoItem // the links set on items 1 to 3, in id order
+		ok    bool
+	}{
+		{"blocked by another", map[int]ToDoItem{1: {BlockedBy: []int{2}}}, true},
+		{"subtask", map[int]ToDoItem{2: {ParentId: 1}, 3: {ParentId: 2}}, true},
+		{"own parent", map[int]ToDoItem{1: {ParentId: 1}}, false},
+		{"blocks itself", map[int]ToDoItem{1: {BlockedBy: []int{1}}}, false},
+		{"missing parent", map[int]ToDoItem{1: {ParentId: 9}}, false},
+		{"missing blocker", map[int]ToDoItem{1: {BlockedBy: []int{9}}}, false},
+		{"2 node cycle", map[int]ToDoItem{1: {BlockedBy: []int{2}}, 2: {BlockedBy: []int{1}}}, false},
+		{"3 node cycle", map[int]ToDoItem{1: {BlockedBy: []int{2}}, 2: {BlockedBy: []int{3}}, 3: {BlockedBy: []int{1}}}, false},
+		//A parent depends on its subtasks, so a subtask cannot wait for it
+		{"subtask blocked by its parent", map[int]ToDoItem{2: {ParentId: 1, BlockedBy: []int{1}}}, false},
+		{"parent cycle", map[int]ToDoItem{1: {ParentId: 2}, 2: {ParentId: 1}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			todo := openTestDB(t)
+			addItems(t, todo, "a", "b", "c")
+
+			var err error
+			for id := 1; id <= 3 && err == nil; id++ {
+				links, ok := test.links[id]
+				if !ok {
+					continue
+				}
+				item, getErr := todo.GetItem(id)
+				if getErr != nil {
+					t.Fatal(getErr)
+				}
+				item.ParentId, item.BlockedBy = links.ParentId, links.BlockedBy
+				err = todo.UpdateItem(item)
+			}
+
+			if test.ok && err != nil {
+				t.Errorf("got %v, want the links accepted", err)
+			}
+			if !test.ok && !errors.Is(err, ErrInvalidItem) {
+				t.Errorf("got %v, want ErrInvalidItem", err)
+			}
+		})
+	}
+}
+
+func TestDoneWhileBlocked(t *testing.T) {
+	todo := openTestDB(t)
+	addItems(t, todo, "blocker", "blocked", "parent")
+	for _, item := range []ToDoItem{
+		{Id: 2, Title: "blocked", BlockedBy: []int{1}},
+		{Id: 1, Title: "blocker", ParentId: 3},
+	} {
+		if err := todo.UpdateItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		id       int
+		blockers []int
+		subtasks []int
+	}{
+		{2, []int{1}, nil},
+		{3, nil, []int{1}},
+	}
+	for _, test := range tests {
+		err := todo.ChangeItemDoneStatus(test.id, true)
+		if !errors.Is(err, ErrBlocked) {
+			t.Errorf("item %d: got %v, want ErrBlocked", test.id, err)
+		}
+		var blocked *BlockedError
+		if !errors.As(err, &blocked) {
+			continue
+		}
+		if blocked.Id != test.id || !reflect.DeepEqual(blocked.Blockers, test.blockers) || !reflect.DeepEqual(blocked.Subtasks, test.subtasks) {
+			t.Errorf("got %+v, want item %d blocked by %v with open subtasks %v", blocked, test.id, test.blockers, test.subtasks)
+		}
+	}
+
+	//Updating the item to done is refused the same way
+	if err := todo.UpdateItem(ToDoItem{Id: 2, Title: "blocked", BlockedBy: []int{1}, IsDone: true}); !errors.Is(err, ErrBlocked) {
+		t.Errorf("got %v updating a blocked item to done, want ErrBlocked", err)
+	}
+
+	//Forcing it gets it done anyway
+	if err := todo.ForceItemDone(2); err != nil {
+		t.Fatal(err)
+	}
+	if item, err := todo.GetItem(2); err != nil || !item.IsDone {
+		t.Errorf("got %+v, %v, want item 2 done", item, err)
+	}
+
+	//Once the blocker is done, so is the rest
+	if err := todo.ChangeItemDoneStatus(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.ChangeItemDoneStatus(3, true); err != nil {
+		t.Errorf("got %v, want the parent done once its subtask is", err)
+	}
+}
+
+// Deleting an item takes it out of the links of the others
+func TestDeleteLinkedItem(t *testing.T) {
+	todo := openTestDB(t)
+	addItems(t, todo, "parent", "child", "blocked")
+	for _, item := range []ToDoItem{
+		{Id: 2, Title: "child", ParentId: 1},
+		{Id: 3, Title: "blocked", BlockedBy: []int{1}},
+	} {
+		if err := todo.UpdateItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := todo.DeleteItem(1); err != nil {
+		t.Fatal(err)
+	}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.ParentId == 1 || len(item.BlockedBy) > 0 {
+			t.Errorf("item %d still links to the deleted item: %+v", item.Id, item)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import "errors"
+
+// Errors returned by the db package.  They usually come wrapped with
+// more detail, so check for them with errors.Is rather than comparing
+// directly:
+//
+//	if errors.Is(err, db.ErrNotFound) {
+//		...
+//	}
+//
+// An item that cannot be marked done because of open blockers or
+// subtasks is reported with a *BlockedError, which is an ErrBlocked.  Use
+// errors.As to find out which items are still open.
+var (
+	// ErrNotFound is returned when an item id is not in the database
+	ErrNotFound = errors.New("item not found in database")
+
+	// ErrDuplicateID is returned when two items claim the same id, either
+	// in the database file itself or in an import using DuplicateFail
+	ErrDuplicateID = errors.New("duplicate item id")
+
+	// ErrCorruptDB is returned when the database file cannot be read
+	ErrCorruptDB = errors.New("database is corrupt")
+
+	// ErrInvalidItem is returned when an item is refused because of what
+	// it holds: links to missing items, a dependency cycle, a bad list
+	// name or repeat rule
+	ErrInvalidItem = errors.New("invalid item")
+
+	// ErrLockTimeout is returned when another process holds the database
+	// lock for longer than the lock timeout, see SetLockTimeout
+	ErrLockTimeout = errors.New("timed out waiting for the database lock")
+
+	// ErrSyncConflict is returned by Sync with SyncFail when both databases
+	// changed the same field of an item
+	ErrSyncConflict = errors.New("conflicting changes")
+
+	// ErrPassphraseRequired is returned when the database is encrypted and
+	// no passphrase was set, see SetPassphrase
+	ErrPassphraseRequired = errors.New("the database is encrypted, a passphrase is needed to open it")
+
+	// ErrWrongPassphrase is returned when the passphrase does not open an
+	// encrypted database
+	ErrWrongPassphrase = errors.New("wrong passphrase for the encrypted database")
+
+	// ErrBlocked is returned, as a *BlockedError, when an item cannot be
+	// marked done because items it depends on are still open
+	ErrBlocked = errors.New("item depends on open items")
+
+	// ErrConflict is returned when an item or the database is not in the
+	// state an operation needs: archiving an item that is not done,
+	// restoring or purging one that is not archived, undoing a change to
+	// an item that changed again since, or encrypting a database twice
+	ErrConflict = errors.New("conflicts with the current state")
+
+	// ErrNothingToUndo is returned by Undo and Redo when the journal has
+	// no change left to take back or make again
+	ErrNothingToUndo = errors.New("nothing to undo or redo")
+
+	// ErrNotSupported is returned when the database backend cannot do what
+	// was asked, like checking or encrypting the database
+	ErrNotSupported = errors.New("not supported by this database backend")
+)
+
+// markedError marks an error as one of the errors above while keeping
+// the message of the original error, which already says what is wrong
+type markedError struct {
+	err  error
+	mark error
+}
+
+func (e markedError) Error() string { return e.err.Error() }
+
+func (e markedError) Unwrap() []error { return []error{e.err, e.mark} }
+
+// mark wraps err so errors.Is finds sentinel in it, nil stays nil
+func mark(err, sentinel error) error {
+	if err == nil {
+		return nil
+	}
+
+	return markedError{err, sentinel}
+}
+
+// invalidItem wraps err as an ErrInvalidItem, nil stays nil
+func invalidItem(err error) error {
+	return mark(err, ErrInvalidItem)
+}
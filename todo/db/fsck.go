@@ -0,0 +1,413 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Check and Repair look after a database that may have been damaged, for
+// example by editing todo.json by hand or by a disk filling up.  Loading
+// such a database fails with ErrCorruptDB, Check says exactly what is
+// wrong with it and Repair fixes what can be fixed.
+//
+// The checks are done in two steps.  First the store reads every item it
+// can out of its file (see fsckStore), remembering where each one was
+// found.  Then the items are checked against the rules the rest of the
+// package keeps: unique ids, a title and a valid list, links that point
+// at items that exist and do not form cycles, and timestamps that match
+// the done status.
+
+// Position is where something was found in the database file.  Line and
+// Column count from 1 and Offset, in bytes, from 0.  Stores that do not
+// keep a text file use Record, the key the item is stored under, instead.
+type Position struct {
+	Line   int   `json:"line,omitempty"`
+	Column int   `json:"column,omitempty"`
+	Offset int64 `json:"offset"`
+	Record int   `json:"record,omitempty"`
+}
+
+func (p Position) String() string {
+	switch {
+	case p.Line > 0:
+		return fmt.Sprintf("line %d, column %d (offset %d)", p.Line, p.Column, p.Offset)
+	case p.Record > 0:
+		return fmt.Sprintf("record %d", p.Record)
+	}
+
+	return ""
+}
+
+// Problem is one thing found wrong with the database
+type Problem struct {
+	Pos Position `json:"pos"`
+	// Id is the item the problem is about, 0 for the file as a whole
+	Id      int    `json:"id,omitempty"`
+	Message string `json:"message"`
+	// Fix says what Repair does about the problem, an empty Fix means
+	// Repair cannot fix it
+	Fix string `json:"fix,omitempty"`
+}
+
+func (p Problem) String() string {
+	var s []string
+	if pos := p.Pos.String(); pos != "" {
+		s = append(s, pos)
+	}
+	if p.Id != 0 {
+		s = append(s, fmt.Sprintf("item %d", p.Id))
+	}
+	s = append(s, p.Message)
+
+	return strings.Join(s, ": ")
+}
+
+// FsckReport is the outcome of Check or Repair
+type FsckReport struct {
+	// Items is the number of items in the database, after the repair if
+	// there was one
+	Items    int       `json:"items"`
+	Problems []Problem `json:"problems"`
+	// Repaired is set once Repair has written the fixed database, and
+	// Backup then names the copy it made of the damaged one
+	Repaired bool   `json:"repaired"`
+	Backup   string `json:"backup,omitempty"`
+}
+
+// Fixable reports whether Repair can fix every problem found
+func (r FsckReport) Fixable() bool {
+	for _, p := range r.Problems {
+		if p.Fix == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fsckStore is implemented by stores that can be checked and repaired
+type fsckStore interface {
+	// scan reads every item it can out of the store, even if the store
+	// cannot be loaded as a whole, along with problems that keep items
+	// from being read at all
+	scan() (fsckScan, error)
+
+	// replace makes items and meta the whole content of the store
+	replace(meta Meta, items []ToDoItem) error
+}
+
+// fsckScan is what a store found in its file
+type fsckScan struct {
+	items    []scannedItem
+	meta     Meta
+	version  int
+	fileTime time.Time
+	problems []Problem
+}
+
+// scannedItem is an item along with where it was found
+type scannedItem struct {
+	item ToDoItem
+	pos  Position
+}
+
+// Check looks the database over and reports everything wrong with it.
+// Nothing is changed.
+func (t *ToDo) Check() (FsckReport, error) {
+	return t.fsck(false)
+}
+
+// Repair checks the database and, if anything is wrong with it, fixes
+// it: items that cannot be read are dropped, duplicate ids are
+// renumbered, links to missing items and links that form cycles are
+// removed, and missing or inconsistent fields are filled in.  Before the
+// fixed database is written the damaged file is copied next to it, the
+// report names the copy.
+//
+// Repair refuses to write anything if there are problems it cannot fix.
+// The repair is not recorded in the journal and cannot be undone, other
+// than by putting the backup back.
+func (t *ToDo) Repair() (FsckReport, error) {
+	return t.fsck(true)
+}
+
+func (t *ToDo) fsck(repair bool) (FsckReport, error) {
+	var report FsckReport
+
+	store, ok := t.store.(fsckStore)
+	if !ok {
+		return report, mark(errors.New("this database backend cannot be checked"), ErrNotSupported)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(context.Background())
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+
+	scan, err := store.scan()
+	if err != nil {
+		return report, err
+	}
+
+	//Bring items written by older code up to date first, so we check them
+	//by today's rules without complaining about what the schema lacked
+	items := make([]ToDoItem, len(scan.items))
+	for i, s := range scan.items {
+		items[i] = s.item
+	}
+	if err := migrate(scan.version, items, scan.fileTime); err != nil {
+		return report, err
+	}
+	for i := range scan.items {
+		scan.items[i].item = items[i]
+	}
+
+	meta, fixed, problems := checkItems(scan)
+	report.Problems = append(scan.problems, problems...)
+	report.Items = len(fixed)
+
+	if !repair || len(report.Problems) == 0 {
+		return report, nil
+	}
+	if !report.Fixable() {
+		return report, mark(errors.New("the database has problems that cannot be repaired"), ErrCorruptDB)
+	}
+
+	report.Backup, err = backupDB(t.dbFileName)
+	if err != nil {
+		return report, fmt.Errorf("backing up the database before repairing it: %w", err)
+	}
+
+	if err := store.replace(meta, fixed); err != nil {
+		return report, err
+	}
+	report.Repaired = true
+
+	//Our copy of the items is out of date, load the fixed file next time
+	t.stamp = nil
+
+	return report, nil
+}
+
+// checkItems checks the items read by a scan and works out the fixed set
+// of items and meta data that Repair would write
+func checkItems(scan fsckScan) (Meta, []ToDoItem, []Problem) {
+	var problems []Problem
+	report := func(s scannedItem, id int, fix, format string, args ...any) {
+		problems = append(problems, Problem{Pos: s.pos, Id: id, Message: fmt.Sprintf(format, args...), Fix: fix})
+	}
+
+	maxId := scan.meta.Seq
+	for _, s := range scan.items {
+		if s.item.Id > maxId {
+			maxId = s.item.Id
+		}
+	}
+
+	//Ids first, the first item to use an id keeps it and later ones are
+	//renumbered after everything in the file
+	items := make(DbMap, len(scan.items))
+	firstSeen := make(map[int]Position, len(scan.items))
+	var order []int
+	seq := maxId
+	for _, s := range scan.items {
+		item := s.item
+
+		if item.Id <= 0 {
+			seq++
+			report(s, 0, fmt.Sprintf("give it id %d", seq), "item %q has no valid id (%d)", item.Title, item.Id)
+			item.Id = seq
+		} else if pos, taken := firstSeen[item.Id]; taken {
+			seq++
+			where := pos.String()
+			if where == "" {
+				where = "another item"
+			}
+			report(s, item.Id, fmt.Sprintf("renumber it to %d", seq), "%s, already used at %s", ErrDuplicateID, where)
+			item.Id = seq
+		}
+		firstSeen[item.Id] = s.pos
+
+		checkItemFields(&item, scan.fileTime, func(fix, format string, args ...any) {
+			report(s, item.Id, fix, format, args...)
+		})
+
+		items[item.Id] = item
+		order = append(order, item.Id)
+	}
+
+	//Links can only be checked once every item is known
+	positions := make(map[int]Position, len(items))
+	for i, s := range scan.items {
+		positions[order[i]] = s.pos
+	}
+	sort.Ints(order)
+	for _, id := range order {
+		item := items[id]
+		s := scannedItem{item, positions[id]}
+
+		if item.ParentId == id {
+			report(s, id, "make it a top level item", "item is its own parent")
+			item.ParentId = 0
+		} else if _, ok := items[item.ParentId]; !ok && item.ParentId != 0 {
+			report(s, id, "make it a top level item", "parent item %d does not exist", item.ParentId)
+			item.ParentId = 0
+		}
+
+		var blockers []int
+		for _, blocker := range item.BlockedBy {
+			if blocker == id {
+				report(s, id, "drop the blocker", "item blocks itself")
+				continue
+			}
+			if _, ok := items[blocker]; !ok {
+				report(s, id, "drop the blocker", "blocking item %d does not exist", blocker)
+				continue
+			}
+			blockers = append(blockers, blocker)
+		}
+		item.BlockedBy = blockers
+		cleanBlockedBy(&item)
+
+		items[id] = item
+	}
+
+	//Break cycles one link at a time, a cycle starting at an item always
+	//goes through one of its own links
+	for _, id := range order {
+		for cycle := findCycle(items, id); cycle != nil; cycle = findCycle(items, id) {
+			s := scannedItem{items[id], positions[id]}
+			next := cycle[1]
+
+			if item := items[id]; containsId(item.BlockedBy, next) {
+				report(s, id, fmt.Sprintf("stop it being blocked by item %d", next), "dependency cycle %s", formatCycle(cycle))
+				item.BlockedBy = removeId(item.BlockedBy, next)
+				items[id] = item
+			} else {
+				child := items[next]
+				report(s, id, fmt.Sprintf("make item %d a top level item", next), "dependency cycle %s", formatCycle(cycle))
+				child.ParentId = 0
+				items[next] = child
+			}
+		}
+	}
+
+	//Databases written before there was a sequence start it from the
+	//highest id, see nextId, a repaired one records it
+	meta := scan.meta
+	meta.Seq = seq
+
+	fixed := make([]ToDoItem, 0, len(items))
+	for _, id := range order {
+		fixed = append(fixed, items[id])
+	}
+
+	return meta, fixed, problems
+}
+
+// checkItemFields checks the fields of a single item that do not depend
+// on other items, fixing them in place and calling report for each one
+// that needed it
+func checkItemFields(item *ToDoItem, fileTime time.Time, report func(fix, format string, args ...any)) {
+	fileTime = fileTime.Truncate(time.Second)
+
+	if strings.TrimSpace(item.Title) == "" {
+		report(`title it "untitled"`, "item has no title")
+		item.Title = "untitled"
+	}
+
+	if item.List == "" {
+		report("put it on the "+DefaultList+" list", "item is not on a list")
+		item.List = DefaultList
+	} else if err := checkListName(item.List); err != nil {
+		report("put it on the "+DefaultList+" list", "%s", err)
+		item.List = DefaultList
+	}
+
+	if _, ok := priorityNames[item.Priority]; !ok {
+		report("drop the priority", "invalid priority %d", item.Priority)
+		item.Priority = PriorityNone
+	}
+
+	if err := item.Recur.check(); err != nil {
+		report("drop the repeat rule", "%s", err)
+		item.Recur = nil
+	}
+
+	if item.CreatedAt.IsZero() {
+		report("use the time the file was last written", "item has no created time")
+		item.CreatedAt = fileTime
+	}
+	if item.UpdatedAt.Before(item.CreatedAt) {
+		report("use the created time", "item was updated before it was created")
+		item.UpdatedAt = item.CreatedAt
+	}
+	if item.IsDone && item.CompletedAt == nil {
+		report("use the updated time", "item is done but has no completed time")
+		completed := item.UpdatedAt
+		item.CompletedAt = &completed
+	}
+	if !item.IsDone && item.CompletedAt != nil {
+		report("clear the completed time", "item is not done but has a completed time")
+		item.CompletedAt = nil
+	}
+	if !item.IsDone && item.ArchivedAt != nil {
+		report("take it out of the archive", "item is archived but not done")
+		item.ArchivedAt = nil
+	}
+}
+
+func containsId(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backupDB copies the database file next to itself, with the time in the
+// name so earlier backups are never overwritten, and returns the name of
+// the copy
+func backupDB(dbFileName string) (string, error) {
+	in, err := os.Open(dbFileName)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	//An encrypted database is only readable by its owner, keep the copy
+	//the same way
+	info, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	backupName := dbFileName + "." + time.Now().Format("20060102-150405") + ".bak"
+	out, err := os.OpenFile(backupName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return "", err
+	}
+
+	return backupName, out.Close()
+}
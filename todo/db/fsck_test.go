@@ -0,0 +1,80 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A file edited by hand with an id used twice and an item without one
+const damagedDB = `{"version": 5, "seq": 2, "items": [
+  {"id": 1, "title": "one", "list": "default", "done": false, "created": "2024-01-01T00:00:00Z", "updated": "2024-01-01T00:00:00Z"},
+  {"id": 2, "title": "two", "list": "default", "done": false, "created": "2024-01-01T00:00:00Z", "updated": "2024-01-01T00:00:00Z"},
+  {"id": 2, "title": "copy of two", "list": "default", "done": false, "created": "2024-01-01T00:00:00Z", "updated": "2024-01-01T00:00:00Z"},
+  {"title": "no id", "list": "default", "done": false, "created": "2024-01-01T00:00:00Z", "updated": "2024-01-01T00:00:00Z"}
+]}`
+
+func TestFsckRenumbersDuplicates(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	if err := os.WriteFile(dbFile, []byte(damagedDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	todo := openDB(t, dbFile)
+
+	_, err := todo.GetAllItems()
+	if !errors.Is(err, ErrCorruptDB) || !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("got %v loading, want ErrCorruptDB and ErrDuplicateID", err)
+	}
+
+	report, err := todo.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Problems) != 2 || !report.Fixable() {
+		t.Fatalf("got %v, want the duplicate and the missing id, both fixable", report.Problems)
+	}
+	if dup := report.Problems[0]; dup.Pos.Line != 4 || !strings.Contains(dup.Message, "line 3") {
+		t.Errorf("got %q at %v, want the duplicate on line 4 pointing at line 3", dup, dup.Pos)
+	}
+	if data, _ := os.ReadFile(dbFile); string(data) != damagedDB {
+		t.Error("Check changed the file")
+	}
+
+	report, err = todo.Repair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Repaired || report.Items != 4 {
+		t.Errorf("got %+v, want 4 items repaired", report)
+	}
+	if data, err := os.ReadFile(report.Backup); err != nil || string(data) != damagedDB {
+		t.Errorf("the backup %s does not hold the damaged file: %v", report.Backup, err)
+	}
+
+	//The first item with an id keeps it, the others go after the highest
+	want := map[int]string{1: "one", 2: "two", 3: "copy of two", 4: "no id"}
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(items), len(want))
+	}
+	for _, item := range items {
+		if want[item.Id] != item.Title {
+			t.Errorf("got item %d %q, want %q", item.Id, item.Title, want[item.Id])
+		}
+	}
+
+	//The sequence moved past the new ids
+	if id, err := todo.AddItem(ToDoItem{Title: "five"}); err != nil || id != 5 {
+		t.Errorf("got id %d, %v, want 5", id, err)
+	}
+
+	report, err = todo.Check()
+	if err != nil || len(report.Problems) != 0 {
+		t.Errorf("got %v, %v checking the repaired file, want no problems", report.Problems, err)
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hooks let other code follow the changes made to the items, for example
+// to post to a chat or update a calendar.  A hook is called once per item
+// for every change saved through this ToDo, including undo, redo, import
+// and sync, after the change is saved and the database is unlocked.  It
+// cannot stop or undo the change: a hook that fails is logged and the
+// next one runs.  Changes made by other processes do not call the hooks
+// of this one.
+
+// HookEvent is the kind of change a hook is called for
+type HookEvent string
+
+const (
+	EventAdd    HookEvent = "add"
+	EventUpdate HookEvent = "update"
+	EventDelete HookEvent = "delete"
+	// EventDone is a change of the done status, either way
+	EventDone HookEvent = "done"
+)
+
+// HookEvents are all of the events, in the order they are documented
+var HookEvents = []HookEvent{EventAdd, EventUpdate, EventDelete, EventDone}
+
+// ParseHookEvent checks that s names a valid HookEvent
+func ParseHookEvent(s string) (HookEvent, error) {
+	for _, event := range HookEvents {
+		if strings.EqualFold(s, string(event)) {
+			return event, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid hook event %q, use add, update, delete or done", s)
+}
+
+// HookPayload is what a hook is told about a change.  Old is nil for an
+// added item and New is nil for a deleted one.
+type HookPayload struct {
+	Event HookEvent `json:"event"`
+	Old   *ToDoItem `json:"old"`
+	New   *ToDoItem `json:"new"`
+}
+
+// Hook is a function called after a change to an item was saved
+type Hook func(payload HookPayload) error
+
+// DefaultHookTimeout is how long a shell hook may run before it is killed
+const DefaultHookTimeout = 30 * time.Second
+
+// hook is a registered Hook and the events it is called for, none means
+// every event
+type hook struct {
+	fn     Hook
+	events []HookEvent
+}
+
+// AddHook registers fn to be called after every change of the given
+// events, or of every event if none are given.  Hooks are called in the
+// order they were added, one after the other.
+//
+// A hook runs after the database is unlocked, so it may use this ToDo
+// again, but changes it makes call the hooks again too.
+func (t *ToDo) AddHook(fn Hook, events ...HookEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.hooks = append(t.hooks, hook{fn: fn, events: events})
+}
+
+// ShellHook returns a Hook that runs command with the shell, sh -c on
+// Unix and cmd /C on Windows.  The payload is written to its standard
+// input as JSON, and TODO_EVENT and TODO_ITEM_ID are set in its
+// environment.  What the command prints goes to standard error, so it
+// does not mix with the output of todo itself.  A command that exits with
+// an error, or runs for longer than DefaultHookTimeout, fails the hook.
+func ShellHook(command string) Hook {
+	return func(payload HookPayload) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultHookTimeout)
+		defer cancel()
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(),
+			"TODO_EVENT="+string(payload.Event),
+			"TODO_ITEM_ID="+strconv.Itoa(payload.id()))
+
+		return cmd.Run()
+	}
+}
+
+// id is the id of the item the payload is about
+func (p HookPayload) id() int {
+	if p.New != nil {
+		return p.New.Id
+	}
+	if p.Old != nil {
+		return p.Old.Id
+	}
+
+	return 0
+}
+
+// hookPayloads turns the changes of a journal entry into the payloads of
+// the hooks.  A change of the done status is EventDone even if other
+// fields changed along with it.
+func hookPayloads(changes []Change) []HookPayload {
+	payloads := make([]HookPayload, 0, len(changes))
+	for _, change := range changes {
+		payload := HookPayload{Old: change.Before, New: change.After}
+		switch {
+		case change.Before == nil:
+			payload.Event = EventAdd
+		case change.After == nil:
+			payload.Event = EventDelete
+		case change.Before.IsDone != change.After.IsDone:
+			payload.Event = EventDone
+		default:
+			payload.Event = EventUpdate
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+// runHooks calls the hooks for the changes saved since it last ran.  It
+// must be called without holding mu, the functions that commit changes
+// defer it before they lock:
+//
+//	defer t.runHooks()
+//	t.mu.Lock()
+//	defer t.mu.Unlock()
+func (t *ToDo) runHooks() {
+	t.mu.Lock()
+	payloads := t.pendingHooks
+	t.pendingHooks = nil
+	hooks := t.hooks
+	t.mu.Unlock()
+
+	for _, payload := range payloads {
+		for _, h := range hooks {
+			if !h.wants(payload.Event) {
+				continue
+			}
+			if err := h.fn(payload); err != nil {
+				log.Printf("%s hook for item %d failed: %v", payload.Event, payload.id(), err)
+			}
+		}
+	}
+}
+
+func (h hook) wants(event HookEvent) bool {
+	if len(h.events) == 0 {
+		return true
+	}
+	for _, e := range h.events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
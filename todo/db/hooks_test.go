@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHookEvents(t *testing.T) {
+	todo := openTestDB(t)
+
+	var all, done []HookPayload
+	todo.AddHook(func(p HookPayload) error {
+		all = append(all, p)
+		return nil
+	})
+	todo.AddHook(func(p HookPayload) error {
+		done = append(done, p)
+		return nil
+	}, EventDone)
+
+	ids := addItems(t, todo, "a")
+	if err := todo.UpdateItem(ToDoItem{Id: ids[0], Title: "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.ChangeItemDoneStatus(ids[0], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.DeleteItem(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HookEvent{EventAdd, EventUpdate, EventDone, EventDelete}
+	if len(all) != len(want) {
+		t.Fatalf("got %d calls, want %d", len(all), len(want))
+	}
+	for i, p := range all {
+		if p.Event != want[i] {
+			t.Errorf("call %d: got %s, want %s", i, p.Event, want[i])
+		}
+	}
+	if all[0].Old != nil || all[0].New == nil || all[3].Old == nil || all[3].New != nil {
+		t.Errorf("got %+v and %+v, want no old item on add and no new one on delete", all[0], all[3])
+	}
+	if all[1].Old.Title != "a" || all[1].New.Title != "renamed" {
+		t.Errorf("got %+v, want the item before and after the update", all[1])
+	}
+	if len(done) != 1 || done[0].Event != EventDone {
+		t.Errorf("got %+v, want only the done event", done)
+	}
+}
+
+// A hook runs after the change is saved, failing cannot take it back
+func TestFailingHook(t *testing.T) {
+	todo := openTestDB(t)
+
+	calls := 0
+	todo.AddHook(func(p HookPayload) error { return errors.New("chat is down") })
+	todo.AddHook(func(p HookPayload) error {
+		calls++
+		return nil
+	})
+
+	ids := addItems(t, todo, "a")
+	if item, err := openDB(t, todo.dbFileName).GetItem(ids[0]); err != nil || item.Title != "a" {
+		t.Errorf("got %+v, %v, want the item saved", item, err)
+	}
+	if calls != 1 {
+		t.Errorf("the hook after the failing one ran %d times, want 1", calls)
+	}
+}
+
+func TestShellHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command is written for sh")
+	}
+
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin.json")
+	env := filepath.Join(dir, "env")
+
+	todo := openTestDB(t)
+	todo.AddHook(ShellHook(`cat > '`+stdin+`'; echo "$TODO_EVENT $TODO_ITEM_ID" > '`+env+`'`), EventAdd)
+	todo.AddHook(ShellHook("exit 3"))
+
+	ids := addItems(t, todo, "Buy milk")
+
+	data, err := os.ReadFile(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload HookPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("the hook got %q on its standard input: %v", data, err)
+	}
+	if payload.Event != EventAdd || payload.Old != nil || payload.New == nil || payload.New.Title != "Buy milk" {
+		t.Errorf("got payload %+v, want the added item", payload)
+	}
+	if data, err := os.ReadFile(env); err != nil || strings.TrimSpace(string(data)) != "add 1" {
+		t.Errorf("got environment %q, %v, want TODO_EVENT add and TODO_ITEM_ID 1", data, err)
+	}
+
+	//The failing hook did not stop the change either
+	if _, err := todo.GetItem(ids[0]); err != nil {
+		t.Error(err)
+	}
+
+	//Only add events run the first hook
+	os.Remove(stdin)
+	if err := todo.DeleteItem(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stdin); err == nil {
+		t.Error("the add hook ran for a delete")
+	}
+}
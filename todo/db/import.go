@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DuplicateMode says what ImportItems does with an item whose id is
+// already used in the database
+type DuplicateMode string
+
+const (
+	// DuplicateSkip leaves the existing item alone and drops the import
+	DuplicateSkip DuplicateMode = "skip"
+	// DuplicateOverwrite replaces the existing item with the import
+	DuplicateOverwrite DuplicateMode = "overwrite"
+	// DuplicateRenumber adds the import under a new id
+	DuplicateRenumber DuplicateMode = "renumber"
+	// DuplicateFail refuses the whole import with ErrDuplicateID
+	DuplicateFail DuplicateMode = "fail"
+)
+
+// ParseDuplicateMode checks that s names a valid DuplicateMode
+func ParseDuplicateMode(s string) (DuplicateMode, error) {
+	for _, mode := range []DuplicateMode{DuplicateSkip, DuplicateOverwrite, DuplicateRenumber, DuplicateFail} {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid duplicate mode %q, use skip, overwrite, renumber or fail", s)
+}
+
+// ImportReport says what ImportItems did, or would do on a dry run, with
+// each imported item
+type ImportReport struct {
+	// Added are the ids of items added under the id they were imported with
+	Added []int `json:"added"`
+	// Renumbered are the items that were given a new id, because they had
+	// none or because of DuplicateRenumber
+	Renumbered []Renumbered `json:"renumbered"`
+	// Overwritten are the ids of existing items replaced by an import
+	Overwritten []int `json:"overwritten"`
+	// Skipped are the ids of imported items dropped by DuplicateSkip
+	Skipped []int `json:"skipped"`
+	// DryRun is set when nothing was actually saved
+	DryRun bool `json:"dryRun"`
+}
+
+// Renumbered records the id an item was imported with (0 if it had none)
+// and the id it was given instead
+type Renumbered struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// ImportItems adds a batch of items, typically read from another tool,
+// to the database.  Unlike AddItem it keeps the ids the items come with
+// as long as they are free.  Items with no id (0) always get a new one,
+// items whose id is taken, by the database or by an earlier item in the
+// same batch, are handled according to mode.
+//
+// Parent and blocker ids point at items of the same batch when those
+// were renumbered, and otherwise at whatever item has that id once the
+// import is done.  The import is refused if they point at items that do
+// not exist or form a cycle.
+//
+// Timestamps carried by the imported items are kept, missing ones are
+// filled in.  The whole import is saved at once and is a single entry in
+// the journal, so one undo reverts all of it.  With dryRun set the report
+// is worked out but nothing is saved.
+func (t *ToDo) ImportItems(items []ToDoItem, mode DuplicateMode, dryRun bool) (ImportReport, error) {
+	report := ImportReport{DryRun: dryRun}
+
+	defer t.runHooks()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(context.Background())
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+
+	if err := t.loadDB(); err != nil {
+		return report, err
+	}
+
+	meta := t.meta
+	seq := t.nextId() - 1
+
+	//Work on a copy of the map so a dry run leaves ours untouched
+	working := make(DbMap, len(t.toDoMap))
+	for id, item := range t.toDoMap {
+		working[id] = item
+	}
+
+	var changes []Change
+	newIds := make(map[int]int)
+	overwritten := make(map[int]bool)
+	for _, item := range items {
+		importedId := item.Id
+		existing, taken := working[item.Id]
+
+		switch {
+		case item.Id <= 0 || (taken && mode == DuplicateRenumber):
+			seq++
+			item.Id = seq
+			report.Renumbered = append(report.Renumbered, Renumbered{From: importedId, To: item.Id})
+			if importedId > 0 {
+				newIds[importedId] = item.Id
+			}
+		case taken && mode == DuplicateFail:
+			return report, fmt.Errorf("item %d: %w", importedId, ErrDuplicateID)
+		case taken && mode == DuplicateSkip:
+			report.Skipped = append(report.Skipped, importedId)
+			continue
+		case taken && mode == DuplicateOverwrite:
+			//A later copy of an item in the same import replaces the
+			//earlier one, which was already reported as added or as
+			//overwriting the item in the database
+			if _, ok := t.toDoMap[importedId]; ok && !overwritten[importedId] {
+				overwritten[importedId] = true
+				report.Overwritten = append(report.Overwritten, importedId)
+			}
+		default:
+			report.Added = append(report.Added, importedId)
+			if item.Id > seq {
+				seq = item.Id
+			}
+		}
+
+		fillImportedTimestamps(&item)
+		cleanBlockedBy(&item)
+		if item.List == "" {
+			item.List = DefaultList
+		}
+		after := item
+		change := Change{Id: item.Id, After: &after}
+		if taken && mode == DuplicateOverwrite {
+			before := existing
+			change.Before = &before
+		}
+
+		working[item.Id] = item
+		changes = append(changes, change)
+	}
+
+	changes = mergeChanges(changes)
+
+	//Now that every id is known, point links at the renumbered items
+	imported := make([]ToDoItem, len(changes))
+	for i, change := range changes {
+		item := change.After
+		if id, ok := newIds[item.ParentId]; ok {
+			item.ParentId = id
+		}
+		for j, blocker := range item.BlockedBy {
+			if id, ok := newIds[blocker]; ok {
+				item.BlockedBy[j] = id
+			}
+		}
+		cleanBlockedBy(item)
+		if err := item.Recur.check(); err != nil {
+			return report, fmt.Errorf("item %d: %w", item.Id, invalidItem(err))
+		}
+		if err := checkListName(item.List); err != nil {
+			return report, fmt.Errorf("item %d: %w", item.Id, invalidItem(err))
+		}
+
+		working[item.Id] = *item
+		imported[i] = *item
+	}
+	if err := checkLinks(working, imported); err != nil {
+		return report, invalidItem(err)
+	}
+
+	if dryRun || len(changes) == 0 {
+		return report, nil
+	}
+
+	meta.Seq = seq
+	err = t.commit(meta, JournalEntry{Op: OpImport, Changes: changes})
+	return report, err
+}
+
+// fillImportedTimestamps sets any timestamp an imported item is missing
+func fillImportedTimestamps(item *ToDoItem) {
+	now := time.Now().Truncate(time.Second)
+
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = now
+	}
+	if item.UpdatedAt.IsZero() {
+		item.UpdatedAt = now
+	}
+	if item.IsDone && item.CompletedAt == nil {
+		item.CompletedAt = &now
+	}
+	if !item.IsDone {
+		item.CompletedAt = nil
+		item.ArchivedAt = nil
+	}
+}
+
+// mergeChanges folds several changes to the same item into one, so a
+// journal entry holds a single before and after image per item
+func mergeChanges(changes []Change) []Change {
+	index := make(map[int]int, len(changes))
+	var merged []Change
+
+	for _, change := range changes {
+		if i, ok := index[change.Id]; ok {
+			merged[i].After = change.After
+			continue
+		}
+		index[change.Id] = len(merged)
+		merged = append(merged, change)
+	}
+
+	return merged
+}
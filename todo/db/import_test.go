@@ -0,0 +1,136 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestImportDuplicateModes(t *testing.T) {
+	//Id 2 is in the database, id 5 is used twice in the import
+	imports := []ToDoItem{
+		{Id: 2, Title: "B"},
+		{Id: 5, Title: "e"},
+		{Id: 5, Title: "e again"},
+		{Title: "no id"},
+	}
+
+	tests := []struct {
+		mode   DuplicateMode
+		report ImportReport
+		titles map[int]string
+	}{
+		{
+			DuplicateSkip,
+			ImportReport{Added: []int{5}, Renumbered: []Renumbered{{0, 6}}, Skipped: []int{2, 5}},
+			map[int]string{1: "a", 2: "b", 5: "e", 6: "no id"},
+		},
+		{
+			//The second 5 replaces the first, the database never had one
+			DuplicateOverwrite,
+			ImportReport{Added: []int{5}, Renumbered: []Renumbered{{0, 6}}, Overwritten: []int{2}},
+			map[int]string{1: "a", 2: "B", 5: "e again", 6: "no id"},
+		},
+		{
+			DuplicateRenumber,
+			ImportReport{Added: []int{5}, Renumbered: []Renumbered{{2, 3}, {5, 6}, {0, 7}}},
+			map[int]string{1: "a", 2: "b", 3: "B", 5: "e", 6: "e again", 7: "no id"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.mode), func(t *testing.T) {
+			todo := openTestDB(t)
+			addItems(t, todo, "a", "b")
+
+			//A dry run reports the same and saves nothing
+			for _, dryRun := range []bool{true, false} {
+				report, err := todo.ImportItems(append([]ToDoItem(nil), imports...), test.mode, dryRun)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := test.report
+				want.DryRun = dryRun
+				if !reflect.DeepEqual(report, want) {
+					t.Errorf("dry run %v: got %+v, want %+v", dryRun, report, want)
+				}
+
+				items, err := todo.GetAllItems()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if dryRun && len(items) != 2 {
+					t.Errorf("the dry run saved %d items", len(items)-2)
+				}
+				if dryRun {
+					continue
+				}
+				got := make(map[int]string, len(items))
+				for _, item := range items {
+					got[item.Id] = item.Title
+				}
+				if !reflect.DeepEqual(got, test.titles) {
+					t.Errorf("got %v, want %v", got, test.titles)
+				}
+			}
+
+			//One undo takes the whole import back
+			entry, err := todo.Undo()
+			if err != nil || entry.Op != OpImport {
+				t.Fatalf("got %v, %v, want the import undone", entry, err)
+			}
+			items, err := todo.GetAllItems()
+			if err != nil || len(items) != 2 || items[1].Title != "b" {
+				t.Errorf("got %v, %v after undo, want the items from before", items, err)
+			}
+		})
+	}
+}
+
+func TestImportDuplicateFail(t *testing.T) {
+	todo := openTestDB(t)
+	addItems(t, todo, "a")
+
+	_, err := todo.ImportItems([]ToDoItem{{Id: 2, Title: "b"}, {Id: 1, Title: "A"}}, DuplicateFail, false)
+	if !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("got %v, want ErrDuplicateID", err)
+	}
+	if items, err := todo.GetAllItems(); err != nil || len(items) != 1 || items[0].Title != "a" {
+		t.Errorf("got %v, %v, want nothing imported", items, err)
+	}
+}
+
+// Links between imported items follow them when they are renumbered
+func TestImportRenumbersLinks(t *testing.T) {
+	todo := openTestDB(t)
+	addItems(t, todo, "a", "b")
+
+	imports := []ToDoItem{
+		{Id: 1, Title: "parent"},
+		{Id: 2, Title: "child", ParentId: 1},
+		{Id: 3, Title: "next", BlockedBy: []int{1}},
+	}
+	if _, err := todo.ImportItems(imports, DuplicateRenumber, false); err != nil {
+		t.Fatal(err)
+	}
+	child, err := todo.GetItem(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Title != "child" || child.ParentId != 3 {
+		t.Errorf("got %+v, want the child of the renumbered parent 3", child)
+	}
+	next, err := todo.GetItem(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Title != "next" || !reflect.DeepEqual(next.BlockedBy, []int{3}) {
+		t.Errorf("got %+v, want it blocked by the renumbered parent 3", next)
+	}
+
+	//A link to an item that does not exist refuses the import
+	_, err = todo.ImportItems([]ToDoItem{{Title: "orphan", ParentId: 42}}, DuplicateRenumber, false)
+	if !errors.Is(err, ErrInvalidItem) {
+		t.Errorf("got %v, want ErrInvalidItem", err)
+	}
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// JournalOp names the kind of change a journal entry records
+type JournalOp string
+
+const (
+	OpAdd    JournalOp = "add"
+	OpUpdate JournalOp = "update"
+	OpDelete JournalOp = "delete"
+	OpDone   JournalOp = "done"
+	OpUndone JournalOp = "undone"
+	OpUndo   JournalOp = "undo"
+	OpRedo   JournalOp = "redo"
+	OpImport JournalOp = "import"
+	OpMove   JournalOp = "move"
+	OpBatch  JournalOp = "batch"
+
+	OpArchive JournalOp = "archive"
+	OpRestore JournalOp = "restore"
+	OpPurge   JournalOp = "purge"
+
+	OpSync JournalOp = "sync"
+)
+
+// Change is the before and after image of one item touched by a journal
+// entry.  Before is nil when the item was added, After is nil when it was
+// deleted.
+type Change struct {
+	Id     int       `json:"id"`
+	Before *ToDoItem `json:"before,omitempty"`
+	After  *ToDoItem `json:"after,omitempty"`
+}
+
+// JournalEntry is one line of the journal.  Every change to the database
+// appends an entry, including undo and redo themselves, so the journal is
+// a complete history that is never rewritten.
+type JournalEntry struct {
+	// Seq is the position of the entry in the journal, starting at 1.  It
+	// is not stored, it is worked out when the journal is read.
+	Seq int `json:"-"`
+
+	Time    time.Time `json:"time"`
+	Op      JournalOp `json:"op"`
+	Changes []Change  `json:"changes,omitempty"`
+
+	// Ref is the Seq of the entry an undo or redo entry reverted or
+	// re-applied
+	Ref int `json:"ref,omitempty"`
+
+	// Undone is set by History on entries that are currently undone
+	Undone bool `json:"-"`
+}
+
+// String describes the entry in a few words, for example
+// `add #5 "Buy milk"`
+func (e JournalEntry) String() string {
+	desc := string(e.Op)
+	if e.Ref != 0 {
+		desc += fmt.Sprintf(" of #%d", e.Ref)
+	}
+
+	if len(e.Changes) == 0 {
+		return desc
+	}
+
+	//Changes to a single item list that item first, any others are items
+	//linked to it that had to change along with it
+	switch {
+	case len(e.Changes) == 1, e.Op == OpAdd, e.Op == OpUpdate, e.Op == OpDelete, e.Op == OpDone, e.Op == OpUndone:
+		change := e.Changes[0]
+		item := change.After
+		if item == nil {
+			item = change.Before
+		}
+		desc = fmt.Sprintf("%s: item %d %q", desc, change.Id, item.Title)
+		if linked := len(e.Changes) - 1; linked > 0 {
+			desc += fmt.Sprintf(" (and %d linked)", linked)
+		}
+		return desc
+	default:
+		return fmt.Sprintf("%s: %d items", desc, len(e.Changes))
+	}
+}
+
+// journalFileName is the journal kept next to the database file
+func (t *ToDo) journalFileName() string {
+	return t.dbFileName + ".journal"
+}
+
+// commit applies the changes in entry to the database and records the
+// entry in the journal.  The caller must hold the database lock, have
+// loaded the database, and have deferred runHooks.
+func (t *ToDo) commit(meta Meta, entry JournalEntry) error {
+	var puts []ToDoItem
+	var deletes []int
+	for _, change := range entry.Changes {
+		if change.After == nil {
+			deletes = append(deletes, change.Id)
+			delete(t.toDoMap, change.Id)
+		} else {
+			puts = append(puts, *change.After)
+			t.toDoMap[change.Id] = *change.After
+		}
+	}
+
+	if err := t.saveDB(meta, puts, deletes); err != nil {
+		return err
+	}
+	t.pendingHooks = append(t.pendingHooks, hookPayloads(entry.Changes)...)
+
+	entry.Time = time.Now().Truncate(time.Second)
+	if err := t.appendJournal(entry); err != nil {
+		return fmt.Errorf("%s was saved but could not be written to the journal: %w", entry.Op, err)
+	}
+
+	return nil
+}
+
+// appendJournal adds an entry to the end of the journal
+func (t *ToDo) appendJournal(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	sealer := t.journalSealer()
+	if line, err = sealJournalLine(sealer, line); err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(t.journalFileName(), os.O_RDWR|os.O_APPEND|os.O_CREATE, journalPerm(sealer))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	//If a crash cut the last entry short it has no newline, start on a
+	//fresh line so this entry does not get glued onto the broken one
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := f.Write(line); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// readJournal returns every entry in the journal, oldest first.  Lines
+// that cannot be parsed (a write cut short by a crash) are skipped but
+// still count towards the entry numbers.  The lines of an encrypted
+// database's journal are decrypted, the database must have been loaded.
+func (t *ToDo) readJournal() ([]JournalEntry, error) {
+	f, err := os.Open(t.journalFileName())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sealer := t.journalSealer()
+	var entries []JournalEntry
+	reader := bufio.NewReader(f)
+	for seq := 1; ; seq++ {
+		line, err := reader.ReadBytes('\n')
+		if text := bytes.TrimSpace(line); len(text) > 0 {
+			var entry JournalEntry
+			if plain, err := openJournalLine(sealer, text); err == nil && json.Unmarshal(plain, &entry) == nil {
+				entry.Seq = seq
+				entries = append(entries, entry)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// journalState replays the undo and redo entries in the journal.  It
+// returns the entries that can be undone and the ones that can be redone,
+// each with the next candidate last.
+func journalState(entries []JournalEntry) (undoable []JournalEntry, redoable []JournalEntry) {
+	bySeq := make(map[int]JournalEntry, len(entries))
+	for _, entry := range entries {
+		bySeq[entry.Seq] = entry
+	}
+
+	for _, entry := range entries {
+		switch entry.Op {
+		case OpUndo:
+			if n := len(undoable); n > 0 && undoable[n-1].Seq == entry.Ref {
+				undoable = undoable[:n-1]
+				redoable = append(redoable, bySeq[entry.Ref])
+			}
+		case OpRedo:
+			if n := len(redoable); n > 0 && redoable[n-1].Seq == entry.Ref {
+				redoable = redoable[:n-1]
+				undoable = append(undoable, bySeq[entry.Ref])
+			}
+		default:
+			//A new change makes anything that was undone impossible to
+			//redo, just like in an editor
+			undoable = append(undoable, entry)
+			redoable = nil
+		}
+	}
+
+	return undoable, redoable
+}
+
+// History returns the journal, newest entry first, with the entries that
+// are currently undone marked as such.
+func (t *ToDo) History() ([]JournalEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	//The journal of an encrypted database is read with the key of the
+	//database, which we only know once it is loaded
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+
+	entries, err := t.readJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	_, redoable := journalState(entries)
+	undone := make(map[int]bool, len(redoable))
+	for _, entry := range redoable {
+		undone[entry.Seq] = true
+	}
+
+	history := make([]JournalEntry, len(entries))
+	for i, entry := range entries {
+		entry.Undone = undone[entry.Seq]
+		history[len(entries)-1-i] = entry
+	}
+
+	return history, nil
+}
+
+// Undo reverts the most recent change that has not been undone yet and
+// returns the journal entry it reverted.  Undoing an add deletes the
+// item, undoing a delete brings it back with its old id.
+//
+// If an item touched by that change has been changed again since (for
+// example by hand editing the file) the undo is refused rather than
+// throwing the newer change away.
+func (t *ToDo) Undo() (JournalEntry, error) {
+	return t.walkJournal(OpUndo)
+}
+
+// Redo re-applies the change most recently undone and returns its journal
+// entry.  Once a new change is made, undone changes can no longer be
+// redone.
+func (t *ToDo) Redo() (JournalEntry, error) {
+	return t.walkJournal(OpRedo)
+}
+
+func (t *ToDo) walkJournal(op JournalOp) (JournalEntry, error) {
+	defer t.runHooks()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(context.Background())
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	defer unlock()
+
+	if err := t.loadDB(); err != nil {
+		return JournalEntry{}, err
+	}
+
+	entries, err := t.readJournal()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	undoable, redoable := journalState(entries)
+	candidates := undoable
+	if op == OpRedo {
+		candidates = redoable
+	}
+	if len(candidates) == 0 {
+		return JournalEntry{}, mark(fmt.Errorf("nothing to %s", op), ErrNothingToUndo)
+	}
+	target := candidates[len(candidates)-1]
+
+	//Undo goes from the after image back to the before image, redo the
+	//other way around
+	changes := make([]Change, 0, len(target.Changes))
+	for _, change := range target.Changes {
+		from, to := change.After, change.Before
+		if op == OpRedo {
+			from, to = to, from
+		}
+
+		current, exists := t.toDoMap[change.Id]
+		var currentPtr *ToDoItem
+		if exists {
+			currentPtr = &current
+		}
+		if !sameItem(currentPtr, from) {
+			return JournalEntry{}, mark(fmt.Errorf("cannot %s #%d, item %d has changed since", op, target.Seq, change.Id), ErrConflict)
+		}
+
+		changes = append(changes, Change{Id: change.Id, Before: from, After: to})
+	}
+
+	err = t.commit(t.meta, JournalEntry{Op: op, Changes: changes, Ref: target.Seq})
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	return target, nil
+}
+
+// sameItem compares two item images by their JSON form, which is how
+// they are stored both in the database and in the journal
+func sameItem(a, b *ToDoItem) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	aJson, errA := json.Marshal(a)
+	bJson, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(aJson, bJson)
+}
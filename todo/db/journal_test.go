@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "a")
+	if err := todo.UpdateItem(ToDoItem{Id: ids[0], Title: "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.DeleteItem(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	//Undo walks back through the delete, the update and the add
+	for _, want := range []struct {
+		op    JournalOp
+		title string
+	}{{OpDelete, "renamed"}, {OpUpdate, "a"}, {OpAdd, ""}} {
+		entry, err := todo.Undo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Op != want.op {
+			t.Errorf("undid %s, want %s", entry.Op, want.op)
+		}
+		item, err := todo.GetItem(ids[0])
+		if want.title == "" {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %v after undoing the add, want ErrNotFound", err)
+			}
+			continue
+		}
+		if err != nil || item.Title != want.title {
+			t.Errorf("got %q, %v after undoing %s, want %q", item.Title, err, want.op, want.title)
+		}
+	}
+	if _, err := todo.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("got %v, want ErrNothingToUndo", err)
+	}
+
+	//Redo brings the add back with its old id
+	entry, err := todo.Redo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Op != OpAdd {
+		t.Errorf("redid %s, want add", entry.Op)
+	}
+	if item, err := todo.GetItem(ids[0]); err != nil || item.Title != "a" {
+		t.Errorf("got %q, %v after redoing the add, want a", item.Title, err)
+	}
+
+	//A new change ends what can be redone
+	if _, err := todo.AddItem(ToDoItem{Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := todo.Redo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("got %v, want ErrNothingToUndo", err)
+	}
+
+	history, err := todo.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 8 {
+		t.Errorf("got %d journal entries, want 8", len(history))
+	}
+}
+
+// A change made behind the journal's back, like editing the file by hand,
+// is not thrown away by an undo
+func TestUndoChangedSince(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "a")
+	if err := todo.UpdateItem(ToDoItem{Id: ids[0], Title: "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := openDB(t, todo.dbFileName)
+	item, err := other.GetItem(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.Title = "edited by hand"
+	if err := other.store.Save(other.meta, []ToDoItem{item}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = todo.Undo()
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("got %v, want ErrConflict", err)
+	}
+	if item, err := todo.GetItem(ids[0]); err != nil || item.Title != "edited by hand" {
+		t.Errorf("got %q, %v, want the hand edit to stay", item.Title, err)
+	}
+}
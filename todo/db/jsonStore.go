@@ -0,0 +1,550 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonStore is the original backend for our todo app.  Every item is
+// kept in a single JSON file, so any change means the whole file has to
+// be rewritten.
+//
+// The file is a JSON object holding the schema version, the id sequence
+// and the array of items:
+//
+//	{
+//	  "version": 5,
+//	  "seq": 4,
+//	  "items": [
+//	    { "id": 1, "title": "Learn Go / GoLang", "list": "default", "done": false, ... }
+//	  ]
+//	}
+//
+// Older databases (a bare JSON array of items, or an object without a
+// version) are still read.  They are upgraded in memory as they are
+// loaded, see migrate, and written back in the new shape on the next
+// save.
+//
+// The file may also be encrypted, see crypt.go.  Everything below works
+// on the plain text, readFile and writeFile take care of the encryption.
+type jsonStore struct {
+	dbFileName string
+
+	// items and meta are the content of the file as of the last Load or
+	// Save.  Save applies its changes on top of this so that it can write
+	// the full file back out.
+	items DbMap
+	meta  Meta
+
+	// passphraseFunc is asked for the passphrase the first time the file
+	// turns out to be encrypted, and passphrase keeps the answer
+	passphraseFunc func() (string, error)
+	passphrase     []byte
+
+	// fileSealer is the key the file was last read or written with, nil
+	// while the file is in plain text
+	fileSealer *sealer
+}
+
+// jsonFile is the layout of the database file on disk
+type jsonFile struct {
+	Version int        `json:"version"`
+	Seq     int        `json:"seq"`
+	Items   []ToDoItem `json:"items"`
+}
+
+func newJSONStore(dbFileName string) (*jsonStore, error) {
+	//Check if the database file exists, if not use initDB to create it
+	//In go, you use the os.Stat function to get information about a file
+	//In this case, we are only checking the error, because if we get an
+	//error we can safely assume that this file does not exist.
+	if _, err := os.Stat(dbFileName); err != nil {
+		//If the file doesn't exist, create it
+		err := initDB(dbFileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &jsonStore{dbFileName: dbFileName}, nil
+}
+
+// initDB is a helper function that creates a new file holding an
+// empty database.  This is used to make sure that the DB
+// file exists for operations on our ToDo struct.  This function
+// should be called when the store is opened if the DB file doesn't
+// exist.  Notice this function does not have a receiver as its
+// used by newJSONStore() to create the DB file
+func initDB(dbFileName string) error {
+	f, err := os.Create(dbFileName)
+	if err != nil {
+		return err
+	}
+
+	// An empty database has not handed out any ids yet and has an
+	// empty array of items, which in json is represented as "[]"
+	_, err = fmt.Fprintf(f, `{"version": %d, "seq": 0, "items": []}`, SchemaVersion)
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return nil
+}
+
+func (s *jsonStore) Load() (DbMap, Meta, error) {
+	info, err := os.Stat(s.dbFileName)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	data, err := s.readFile()
+	if err != nil {
+		return nil, Meta{}, err
+	}
+
+	//Now let's unmarshal the data, databases written before we kept an
+	//id sequence are just the array of items (version 0), and the first
+	//object shaped ones did not record a version yet (version 1)
+	var file jsonFile
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &file.Items)
+	} else {
+		err = json.Unmarshal(data, &file)
+		if err == nil && file.Version == 0 {
+			file.Version = 1
+		}
+	}
+	if err != nil {
+		if pos := jsonPosition(data, jsonErrorOffset(data, err)); pos.Line > 0 {
+			return nil, Meta{}, fmt.Errorf("%w: %s: %s: %w", ErrCorruptDB, s.dbFileName, pos, err)
+		}
+		return nil, Meta{}, fmt.Errorf("%w: %s: %w", ErrCorruptDB, s.dbFileName, err)
+	}
+
+	if err := migrate(file.Version, file.Items, info.ModTime()); err != nil {
+		return nil, Meta{}, err
+	}
+
+	//Now let's iterate over our slice and add each item to our map
+	items := make(DbMap, len(file.Items))
+	for _, item := range file.Items {
+		if _, ok := items[item.Id]; ok {
+			return nil, Meta{}, fmt.Errorf("%w: %s: %w %d", ErrCorruptDB, s.dbFileName, ErrDuplicateID, item.Id)
+		}
+		items[item.Id] = item
+	}
+
+	s.items = items
+	s.meta = Meta{Seq: file.Seq}
+
+	return s.copyItems(), s.meta, nil
+}
+
+func (s *jsonStore) Save(meta Meta, puts []ToDoItem, deletes []int) error {
+	if s.items == nil {
+		if _, _, err := s.Load(); err != nil {
+			return err
+		}
+	}
+
+	items := s.copyItems()
+	for _, id := range deletes {
+		delete(items, id)
+	}
+	for _, item := range puts {
+		items[item.Id] = item
+	}
+
+	if err := s.writeItems(meta, items); err != nil {
+		return err
+	}
+
+	s.items = items
+	s.meta = meta
+
+	return nil
+}
+
+// writeItems writes items and meta out as the whole database file
+func (s *jsonStore) writeItems(meta Meta, items DbMap) error {
+	//1. Convert our map into a slice, sorted so the file is stable
+	//   from one save to the next
+	file := jsonFile{
+		Version: SchemaVersion,
+		Seq:     meta.Seq,
+		Items:   make([]ToDoItem, 0, len(items)),
+	}
+	for _, item := range items {
+		file.Items = append(file.Items, item)
+	}
+	sort.Slice(file.Items, func(i, j int) bool {
+		return file.Items[i].Id < file.Items[j].Id
+	})
+
+	//2. Marshal the file into json, lets pretty print it, but
+	//   this is not required
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	//3. Write the json to our file, going through a temp file so a
+	//   crash part way through can never leave a corrupted database
+	return s.writeFile(data)
+}
+
+// readFile returns the plain text of the database file, decrypting it
+// if it is encrypted
+func (s *jsonStore) readFile() ([]byte, error) {
+	data, err := os.ReadFile(s.dbFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := parseEncrypted(data)
+	if !ok {
+		s.fileSealer = nil
+		return data, nil
+	}
+
+	//Deriving the key is slow on purpose, only do it again when the file
+	//was encrypted with another salt
+	if s.fileSealer == nil || !s.fileSealer.sameKey(file.Encryption) {
+		if s.passphrase == nil {
+			if s.passphraseFunc == nil {
+				return nil, fmt.Errorf("%w: %s", ErrPassphraseRequired, s.dbFileName)
+			}
+			passphrase, err := s.passphraseFunc()
+			if err != nil {
+				return nil, err
+			}
+			s.passphrase = []byte(passphrase)
+		}
+
+		sealer, err := deriveSealer(s.passphrase, file.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		s.fileSealer = sealer
+	}
+
+	plain, err := s.fileSealer.openFile(file)
+	if err != nil {
+		s.fileSealer = nil
+		return nil, fmt.Errorf("%w: %s", err, s.dbFileName)
+	}
+
+	return plain, nil
+}
+
+// writeFile replaces the database file with data, encrypted if the file
+// is encrypted
+func (s *jsonStore) writeFile(data []byte) error {
+	if s.fileSealer == nil {
+		return writeFileAtomic(s.dbFileName, data, 0644)
+	}
+
+	sealed, err := s.fileSealer.sealFile(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.dbFileName, sealed, 0600)
+}
+
+func (s *jsonStore) setPassphrase(passphrase func() (string, error)) {
+	s.passphraseFunc = passphrase
+	s.passphrase = nil
+}
+
+func (s *jsonStore) encrypted() (bool, error) {
+	//Someone else may have encrypted or decrypted the file since we last
+	//read it
+	if _, _, err := s.Load(); err != nil {
+		return false, err
+	}
+
+	return s.fileSealer != nil, nil
+}
+
+func (s *jsonStore) sealer() *sealer {
+	return s.fileSealer
+}
+
+func (s *jsonStore) reseal(sealer *sealer) error {
+	//Always start from the file, it is what gets encrypted
+	if _, _, err := s.Load(); err != nil {
+		return err
+	}
+
+	old := s.fileSealer
+	s.fileSealer = sealer
+	if err := s.writeItems(s.meta, s.items); err != nil {
+		s.fileSealer = old
+		return err
+	}
+
+	return nil
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
+
+func (s *jsonStore) copyItems() DbMap {
+	items := make(DbMap, len(s.items))
+	for id, item := range s.items {
+		items[id] = item
+	}
+
+	return items
+}
+
+func (s *jsonStore) scan() (fsckScan, error) {
+	info, err := os.Stat(s.dbFileName)
+	if err != nil {
+		return fsckScan{}, err
+	}
+
+	data, err := s.readFile()
+	if err != nil {
+		return fsckScan{}, err
+	}
+
+	scan := scanJSON(data)
+	scan.fileTime = info.ModTime()
+	return scan, nil
+}
+
+func (s *jsonStore) replace(meta Meta, items []ToDoItem) error {
+	file := jsonFile{
+		Version: SchemaVersion,
+		Seq:     meta.Seq,
+		Items:   items,
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := s.writeFile(data); err != nil {
+		return err
+	}
+
+	//Forget what we had, the next Load reads the new file
+	s.items = nil
+	return nil
+}
+
+// scanJSON reads the items out of the content of a database file.  The
+// file is walked one item at a time so each item's position is known and
+// an item that does not fit the schema does not stop the others from
+// being read.  If the file is not valid JSON at all, the items are
+// salvaged from whatever is left, see salvageJSON.
+func scanJSON(data []byte) fsckScan {
+	var scan fsckScan
+
+	err := walkJSON(data, &scan)
+	if err == nil {
+		return scan
+	}
+
+	offset := jsonErrorOffset(data, err)
+	scan.problems = append(scan.problems[:0], Problem{
+		Pos:     jsonPosition(data, offset),
+		Message: "cannot read the file: " + err.Error(),
+		Fix:     "keep the items that can still be read",
+	})
+	scan.items = salvageJSON(data, &scan)
+
+	return scan
+}
+
+// walkJSON reads a database file written in any of the layouts jsonStore
+// understands, see Load
+func walkJSON(data []byte, scan *fsckScan) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		if err := walkJSONItems(data, dec, scan); err != nil {
+			return err
+		}
+
+	case json.Delim('{'):
+		scan.version = 1
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			switch key {
+			case "version":
+				err = dec.Decode(&scan.version)
+			case "seq":
+				err = dec.Decode(&scan.meta.Seq)
+			case "items":
+				if tok, err = dec.Token(); err == nil && tok != json.Delim('[') {
+					err = fmt.Errorf("items should be an array")
+				}
+				if err == nil {
+					err = walkJSONItems(data, dec, scan)
+				}
+			default:
+				var skip json.RawMessage
+				err = dec.Decode(&skip)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("the database should be a JSON object")
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the database")
+	}
+
+	return nil
+}
+
+// walkJSONItems reads the elements of the items array, the opening [ has
+// already been read
+func walkJSONItems(data []byte, dec *json.Decoder, scan *fsckScan) error {
+	for dec.More() {
+		offset := skipJSONSpace(data, dec.InputOffset())
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		var item ToDoItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			scan.problems = append(scan.problems, Problem{
+				Pos:     jsonPosition(data, offset),
+				Message: "cannot read item: " + err.Error(),
+				Fix:     "drop it",
+			})
+			continue
+		}
+
+		scan.items = append(scan.items, scannedItem{item, jsonPosition(data, offset)})
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+var (
+	jsonSeqField     = regexp.MustCompile(`"seq"\s*:\s*(\d+)`)
+	jsonVersionField = regexp.MustCompile(`"version"\s*:\s*(\d+)`)
+)
+
+// salvageJSON picks out every complete item object in a file that is not
+// valid JSON, for example one that was cut off part way through.  Any
+// object with a title that reads as a ToDoItem counts as an item.  The
+// id sequence and version are recovered too if they can be found.
+func salvageJSON(data []byte, scan *fsckScan) []scannedItem {
+	var items []scannedItem
+
+	scan.version = 1
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		scan.version = 0
+	}
+	if m := jsonVersionField.FindSubmatch(data); m != nil {
+		scan.version, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := jsonSeqField.FindSubmatch(data); m != nil {
+		scan.meta.Seq, _ = strconv.Atoi(string(m[1]))
+	}
+
+	for i := 0; i < len(data); i++ {
+		if data[i] != '{' {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(data[i:]))
+		var fields map[string]json.RawMessage
+		if dec.Decode(&fields) != nil {
+			continue
+		}
+		if _, ok := fields["title"]; !ok {
+			continue
+		}
+
+		end := i + int(dec.InputOffset())
+		var item ToDoItem
+		if json.Unmarshal(data[i:end], &item) != nil {
+			continue
+		}
+
+		items = append(items, scannedItem{item, jsonPosition(data, int64(i))})
+		i = end - 1
+	}
+
+	return items
+}
+
+// jsonErrorOffset works out where in data a decoding error happened
+func jsonErrorOffset(data []byte, err error) int64 {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		return typeErr.Offset
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return int64(len(data))
+	}
+
+	return -1
+}
+
+// jsonPosition turns a byte offset into data into a line and column, a
+// negative offset gives an empty Position
+func jsonPosition(data []byte, offset int64) Position {
+	if offset < 0 {
+		return Position{}
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+
+	return Position{Line: line, Column: column, Offset: offset}
+}
+
+// skipJSONSpace moves offset past white space and the comma between
+// array elements, to the start of the next value
+func skipJSONSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+		offset++
+	}
+
+	return offset
+}
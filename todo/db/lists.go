@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultList is the list items go on when no list is given, and the
+// list every item of a database from before named lists ends up on
+const DefaultList = "default"
+
+// ParseListName checks a list name typed in by a user
+func ParseListName(s string) (string, error) {
+	name := strings.TrimSpace(s)
+	if name == "" {
+		return "", errors.New("list name cannot be empty")
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		return "", fmt.Errorf("invalid list name %q, list names cannot contain spaces", name)
+	}
+
+	return name, nil
+}
+
+// checkListName makes sure the list of an item, which may have come
+// straight from JSON, is a valid name
+func checkListName(name string) error {
+	parsed, err := ParseListName(name)
+	if err == nil && parsed != name {
+		err = fmt.Errorf("invalid list name %q, list names cannot start or end with spaces", name)
+	}
+
+	return err
+}
+
+// ListStats counts the items on one list
+type ListStats struct {
+	Name    string `json:"name"`
+	Total   int    `json:"total"`
+	Open    int    `json:"open"`
+	Done    int    `json:"done"`
+	Overdue int    `json:"overdue"`
+}
+
+// Lists returns every list that has items on it, with counts of its
+// items, sorted by name.  Overdue items are open items past their due
+// date, see ToDoItem.IsOverdue.  Archived items are not counted.
+func (t *ToDo) Lists() ([]ListStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byName := make(map[string]*ListStats)
+	for _, item := range t.toDoMap {
+		if item.ArchivedAt != nil {
+			continue
+		}
+
+		stats, ok := byName[item.List]
+		if !ok {
+			stats = &ListStats{Name: item.List}
+			byName[item.List] = stats
+		}
+
+		stats.Total++
+		if item.IsDone {
+			stats.Done++
+		} else {
+			stats.Open++
+			if item.IsOverdue(now) {
+				stats.Overdue++
+			}
+		}
+	}
+
+	lists := make([]ListStats, 0, len(byName))
+	for _, stats := range byName {
+		lists = append(lists, *stats)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].Name < lists[j].Name
+	})
+
+	return lists, nil
+}
+
+// MoveItems puts items on another list.  Subtasks go along with their
+// parent, so a moved item never leaves part of itself behind.  The ids of
+// every item that moved are returned, items already on the list are left
+// alone.  All of the moves are a single entry in the journal.
+func (t *ToDo) MoveItems(ids []int, list string) ([]int, error) {
+	var moved []int
+	err := t.batch(context.Background(), OpMove, func(b *Batch) error {
+		var err error
+		moved, err = b.Move(ids, list)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListsOverdue(t *testing.T) {
+	todo := openTestDB(t)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	yesterdayNoon := yesterday.Add(12 * time.Hour)
+
+	for _, item := range []ToDoItem{
+		//Due today without a time is not overdue until the day is over
+		{Title: "today", Due: &today},
+		{Title: "yesterday", Due: &yesterday},
+		{Title: "yesterday noon", Due: &yesterdayNoon},
+		{Title: "done late", Due: &yesterday, IsDone: true},
+		{Title: "elsewhere", List: "work", Due: &yesterday},
+	} {
+		if _, err := todo.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lists, err := todo.Lists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ListStats{
+		{Name: DefaultList, Total: 4, Open: 3, Done: 1, Overdue: 2},
+		{Name: "work", Total: 1, Open: 1, Overdue: 1},
+	}
+	if len(lists) != len(want) {
+		t.Fatalf("got %+v, want %+v", lists, want)
+	}
+	for i := range want {
+		if lists[i] != want[i] {
+			t.Errorf("got %+v, want %+v", lists[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+// DefaultLockTimeout is how long a ToDo waits to get the database lock
+// before giving up with an error
+const DefaultLockTimeout = 5 * time.Second
+
+// lockRetryDelay is how often we retry to grab a lock held by another
+// process
+const lockRetryDelay = 50 * time.Millisecond
+
+// SetLockTimeout changes how long operations that modify the database
+// wait for the lock held by other todo processes.
+func (t *ToDo) SetLockTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lockTimeout = timeout
+}
+
+// lockDB takes the advisory lock that guards the database file.  The
+// lock lives in a separate <db>.lock file so it works the same way for
+// every backend, and it is an OS level lock, so it is released even if
+// the process crashes while holding it.
+//
+// Every function that loads, modifies and saves the database must hold
+// the lock for the whole cycle, otherwise two todo processes running at
+// the same time can each load the old file and the last one to save
+// silently drops the other's change.
+//
+// We give up waiting for the lock after the lock timeout, or earlier if
+// ctx is done first.  It returns a function that releases the lock.
+func (t *ToDo) lockDB(ctx context.Context) (func(), error) {
+	waitCtx, cancel := context.WithTimeout(ctx, t.lockTimeout)
+	defer cancel()
+
+	lock := flock.New(t.dbFileName + ".lock")
+	locked, err := lock.TryLockContext(waitCtx, lockRetryDelay)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	if !locked {
+		//The caller giving up is not a timeout on our side
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w on %s after %s, another todo process may be using it",
+			ErrLockTimeout, t.dbFileName, t.lockTimeout)
+	}
+
+	return func() { lock.Unlock() }, nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+func TestLockTimeout(t *testing.T) {
+	todo := openTestDB(t)
+	todo.SetLockTimeout(100 * time.Millisecond)
+
+	//Another process holding the lock
+	lock := flock.New(todo.dbFileName + ".lock")
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	_, err := todo.AddItem(ToDoItem{Title: "blocked"})
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("got %v, want ErrLockTimeout", err)
+	}
+
+	//Giving up on our side is not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	todo.SetLockTimeout(time.Minute)
+	_, err = todo.AddItemContext(ctx, ToDoItem{Title: "canceled"})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	lock.Unlock()
+	if _, err := todo.AddItem(ToDoItem{Title: "free"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Two processes adding at the same time must not lose each other's items
+func TestConcurrentAdds(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	a := openDB(t, dbFile)
+	b := openDB(t, dbFile)
+
+	const perDB = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perDB)
+	for _, todo := range []*ToDo{a, b} {
+		wg.Add(1)
+		go func(todo *ToDo) {
+			defer wg.Done()
+			for i := 0; i < perDB; i++ {
+				if _, err := todo.AddItem(ToDoItem{Title: "item"}); err != nil {
+					errs <- err
+				}
+			}
+		}(todo)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	items, err := openDB(t, dbFile).GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2*perDB {
+		t.Fatalf("got %d items, want %d", len(items), 2*perDB)
+	}
+	seen := make(map[int]bool)
+	for _, item := range items {
+		if seen[item.Id] {
+			t.Errorf("id %d handed out twice", item.Id)
+		}
+		seen[item.Id] = true
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "file.json")
+
+	for _, data := range []string{"first", "second, and longer"} {
+		if err := writeFileAtomic(fileName, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("got %q, want %q", got, data)
+		}
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("got mode %v, want 0600", perm)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
+
+// A failed write leaves no temporary file behind
+func TestWriteFileAtomicFailure(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "file.json")
+	if err := writeFileAtomic(fileName, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	//Renaming a file over a directory fails after the data was written
+	target := filepath.Join(dir, "dir")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "keep"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(target, []byte("new"), 0600); err == nil {
+		t.Fatal("writing over a directory worked")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d files, want the file and the directory only", len(entries))
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the database layout written by this
+// code.  Every time the layout changes the version goes up by one and a
+// migration is added below to upgrade databases written by older code.
+//
+//	0  a bare JSON array of items
+//	1  a JSON object holding the id sequence next to the items
+//	2  items carry due date, priority, tags, notes and timestamps
+//	3  every item belongs to a named list
+//	4  done items can be archived
+//	5  the file can be encrypted
+const SchemaVersion = 5
+
+// migrations[v] upgrades items from version v to version v+1.  fileTime
+// is the last time the database was written, the best guess we have for
+// anything the old layout did not record.
+var migrations = []func(items []ToDoItem, fileTime time.Time){
+	0: func(items []ToDoItem, fileTime time.Time) {},
+	1: addItemTimestamps,
+	2: addDefaultList,
+	3: func(items []ToDoItem, fileTime time.Time) {},
+	4: func(items []ToDoItem, fileTime time.Time) {},
+}
+
+// migrate brings items written with the given schema version up to
+// SchemaVersion.  Items are upgraded in place.
+func migrate(version int, items []ToDoItem, fileTime time.Time) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("database has schema version %d, this todo only understands up to version %d",
+			version, SchemaVersion)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		migrations[v](items, fileTime)
+	}
+
+	return nil
+}
+
+// addItemTimestamps fills in the timestamps version 2 added.  We don't
+// know when old items were created or finished, so the last time the
+// file was written stands in for both.
+func addItemTimestamps(items []ToDoItem, fileTime time.Time) {
+	fileTime = fileTime.Truncate(time.Second)
+
+	for i := range items {
+		if items[i].CreatedAt.IsZero() {
+			items[i].CreatedAt = fileTime
+		}
+		if items[i].UpdatedAt.IsZero() {
+			items[i].UpdatedAt = fileTime
+		}
+		if items[i].IsDone && items[i].CompletedAt == nil {
+			completed := fileTime
+			items[i].CompletedAt = &completed
+		}
+	}
+}
+
+// addDefaultList puts the items of databases written before there were
+// named lists on the default list
+func addDefaultList(items []ToDoItem, fileTime time.Time) {
+	for i := range items {
+		if items[i].List == "" {
+			items[i].List = DefaultList
+		}
+	}
+}
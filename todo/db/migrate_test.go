@@ -0,0 +1,80 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The original database was a bare array of items
+func TestMigrateLegacyArray(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	legacy := `[
+  {"id": 1, "title": "done one", "done": true},
+  {"id": 4, "title": "open one", "done": false}
+]`
+	if err := os.WriteFile(dbFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileTime := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(dbFile, fileTime, fileTime); err != nil {
+		t.Fatal(err)
+	}
+
+	todo := openDB(t, dbFile)
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	for _, item := range items {
+		if item.List != DefaultList {
+			t.Errorf("item %d is on list %q, want %q", item.Id, item.List, DefaultList)
+		}
+		if !item.CreatedAt.Equal(fileTime) || !item.UpdatedAt.Equal(fileTime) {
+			t.Errorf("item %d has times %v and %v, want the file time", item.Id, item.CreatedAt, item.UpdatedAt)
+		}
+		if item.IsDone != (item.CompletedAt != nil) {
+			t.Errorf("item %d done %v but completed at %v", item.Id, item.IsDone, item.CompletedAt)
+		}
+	}
+
+	//Without a sequence the next id follows the highest one
+	id, err := todo.AddItem(ToDoItem{Title: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 5 {
+		t.Errorf("got id %d, want 5", id)
+	}
+
+	//The change saved the file in the current layout
+	data, err := os.ReadFile(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file jsonFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("saved file is not an object: %v", err)
+	}
+	if file.Version != SchemaVersion || file.Seq != 5 || len(file.Items) != 3 {
+		t.Errorf("got version %d, seq %d and %d items, want %d, 5 and 3",
+			file.Version, file.Seq, len(file.Items), SchemaVersion)
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "todo.json")
+	data := []byte(`{"version": 99, "seq": 0, "items": []}`)
+	if err := os.WriteFile(dbFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openDB(t, dbFile).GetAllItems(); err == nil {
+		t.Fatal("loaded a database from a newer version")
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priority ranks how important a ToDoItem is.  The zero value means no
+// priority was given.  Higher values are more important, so priorities
+// can be compared and sorted as plain numbers.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+var priorityNames = map[Priority]string{
+	PriorityNone:   "none",
+	PriorityLow:    "low",
+	PriorityMedium: "medium",
+	PriorityHigh:   "high",
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+
+	return strconv.Itoa(int(p))
+}
+
+// ParsePriority accepts a priority name (none, low, medium, high), its
+// first letter, or its number
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for p, name := range priorityNames {
+		if s == name || (s != "" && s == name[:1]) {
+			return p, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(s); err == nil && n >= int(PriorityNone) && n <= int(PriorityHigh) {
+		return Priority(n), nil
+	}
+
+	return PriorityNone, fmt.Errorf("invalid priority %q, use none, low, medium or high", s)
+}
+
+// MarshalText stores priorities by name so the database file stays
+// readable
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	parsed, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+
+	*p = parsed
+	return nil
+}
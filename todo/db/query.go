@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey names the field Find orders its results by
+type SortKey string
+
+const (
+	SortById       SortKey = "id"
+	SortByDue      SortKey = "due"
+	SortByPriority SortKey = "priority"
+	SortByTitle    SortKey = "title"
+	SortByCreated  SortKey = "created"
+	SortByUpdated  SortKey = "updated"
+)
+
+// SortKeys lists every valid SortKey
+var SortKeys = []SortKey{SortById, SortByDue, SortByPriority, SortByTitle, SortByCreated, SortByUpdated}
+
+// ParseSortKey checks that s names a valid SortKey
+func ParseSortKey(s string) (SortKey, error) {
+	for _, key := range SortKeys {
+		if strings.EqualFold(s, string(key)) {
+			return key, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid sort key %q, use one of %v", s, SortKeys)
+}
+
+// Query describes which items Find should return and in what order.  The
+// zero value matches every item and sorts them by id.  All of the filters
+// that are set must match for an item to be returned.
+type Query struct {
+	// List only matches items on this list, ignoring case
+	List string
+
+	// Done only matches items with this done status
+	Done *bool
+
+	// Archived only matches items that are (true) or are not (false) in
+	// the archive
+	Archived *bool
+
+	// Tags only matches items that have every one of these tags
+	Tags []string
+
+	// Priorities only matches items with one of these priorities
+	Priorities []Priority
+
+	// DueBefore and DueAfter only match items with a due date in the
+	// range.  Items without a due date never match either of them.
+	DueBefore *time.Time
+	DueAfter  *time.Time
+
+	// Title only matches items whose title contains this text, ignoring
+	// case.  TitleRegex only matches titles the expression matches.
+	Title      string
+	TitleRegex *regexp.Regexp
+
+	// SortBy orders the results, ties are broken by id.  Items without a
+	// due date sort after those with one, and priorities sort from high
+	// to low.  Reverse flips the whole order.
+	SortBy  SortKey
+	Reverse bool
+
+	// Offset skips that many results, and Limit caps how many are
+	// returned after that (0 means no limit)
+	Offset int
+	Limit  int
+}
+
+// Match reports whether item passes every filter in the query
+func (q Query) Match(item ToDoItem) bool {
+	if q.List != "" && !strings.EqualFold(item.List, q.List) {
+		return false
+	}
+
+	if q.Done != nil && item.IsDone != *q.Done {
+		return false
+	}
+
+	if q.Archived != nil && (item.ArchivedAt != nil) != *q.Archived {
+		return false
+	}
+
+	for _, tag := range q.Tags {
+		if !item.HasTag(tag) {
+			return false
+		}
+	}
+
+	if len(q.Priorities) > 0 {
+		found := false
+		for _, p := range q.Priorities {
+			if item.Priority == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.DueBefore != nil && (item.Due == nil || !item.Due.Before(*q.DueBefore)) {
+		return false
+	}
+	if q.DueAfter != nil && (item.Due == nil || !item.Due.After(*q.DueAfter)) {
+		return false
+	}
+
+	if q.Title != "" && !strings.Contains(strings.ToLower(item.Title), strings.ToLower(q.Title)) {
+		return false
+	}
+	if q.TitleRegex != nil && !q.TitleRegex.MatchString(item.Title) {
+		return false
+	}
+
+	return true
+}
+
+// HasTag reports whether the item is tagged with tag, ignoring case
+func (item ToDoItem) HasTag(tag string) bool {
+	for _, t := range item.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Find returns the items that match the query, sorted and paged as the
+// query asks.
+// Preconditions:   (1) The database file must exist and be a valid
+//
+// Postconditions:
+//
+//	 (1) The matching items will be returned, if any exist
+//		(2) If there is an error, it will be returned
+//			along with an empty slice
+//		(3) The database file will not be modified
+func (t *ToDo) Find(q Query) ([]ToDoItem, error) {
+	return t.FindContext(context.Background(), q)
+}
+
+// FindContext is Find with a context
+func (t *ToDo) FindContext(ctx context.Context, q Query) ([]ToDoItem, error) {
+	if err := ctx.Err(); err != nil {
+		return []ToDoItem{}, err
+	}
+
+	if q.SortBy == "" {
+		q.SortBy = SortById
+	}
+	less, err := lessFunc(q.SortBy)
+	if err != nil {
+		return []ToDoItem{}, err
+	}
+
+	t.mu.Lock()
+	err = t.loadDB()
+	toDoItems := []ToDoItem{}
+	if err == nil {
+		for _, item := range t.toDoMap {
+			if q.Match(item) {
+				toDoItems = append(toDoItems, item)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		return []ToDoItem{}, err
+	}
+
+	sort.Slice(toDoItems, func(i, j int) bool {
+		a, b := toDoItems[i], toDoItems[j]
+		if q.Reverse {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.Id < b.Id
+	})
+
+	if q.Offset > 0 {
+		if q.Offset >= len(toDoItems) {
+			return []ToDoItem{}, nil
+		}
+		toDoItems = toDoItems[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(toDoItems) {
+		toDoItems = toDoItems[:q.Limit]
+	}
+
+	return toDoItems, nil
+}
+
+// lessFunc returns the comparison used to sort by key
+func lessFunc(key SortKey) (func(a, b ToDoItem) bool, error) {
+	switch key {
+	case SortById:
+		return func(a, b ToDoItem) bool { return a.Id < b.Id }, nil
+	case SortByDue:
+		return func(a, b ToDoItem) bool {
+			if a.Due == nil || b.Due == nil {
+				return a.Due != nil && b.Due == nil
+			}
+			return a.Due.Before(*b.Due)
+		}, nil
+	case SortByPriority:
+		return func(a, b ToDoItem) bool { return a.Priority > b.Priority }, nil
+	case SortByTitle:
+		return func(a, b ToDoItem) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }, nil
+	case SortByCreated:
+		return func(a, b ToDoItem) bool { return a.CreatedAt.Before(b.CreatedAt) }, nil
+	case SortByUpdated:
+		return func(a, b ToDoItem) bool { return a.UpdatedAt.Before(b.UpdatedAt) }, nil
+	default:
+		return nil, fmt.Errorf("invalid sort key %q, use one of %v", key, SortKeys)
+	}
+}
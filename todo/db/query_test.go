@@ -0,0 +1,108 @@
+package db
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestQueryMatch(t *testing.T) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	item := ToDoItem{
+		Id: 1, Title: "Buy Milk", List: "Home", Tags: []string{"shop", "Urgent"},
+		Priority: PriorityHigh, Due: &due,
+	}
+	yes, no := true, false
+	before, after := due.Add(time.Hour), due.Add(-time.Hour)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  bool
+	}{
+		{"zero query", Query{}, true},
+		{"list ignores case", Query{List: "home"}, true},
+		{"other list", Query{List: "work"}, false},
+		{"not done", Query{Done: &no}, true},
+		{"done", Query{Done: &yes}, false},
+		{"not archived", Query{Archived: &no}, true},
+		{"every tag, ignoring case", Query{Tags: []string{"SHOP", "urgent"}}, true},
+		{"one tag missing", Query{Tags: []string{"shop", "later"}}, false},
+		{"one of the priorities", Query{Priorities: []Priority{PriorityLow, PriorityHigh}}, true},
+		{"other priority", Query{Priorities: []Priority{PriorityLow}}, false},
+		{"due in range", Query{DueBefore: &before, DueAfter: &after}, true},
+		{"due too late", Query{DueBefore: &after}, false},
+		{"search ignores case", Query{Title: "MILK"}, true},
+		{"search misses", Query{Title: "bread"}, false},
+		{"regex", Query{TitleRegex: regexp.MustCompile(`^Buy\s`)}, true},
+		{"regex misses", Query{TitleRegex: regexp.MustCompile(`^buy`)}, false},
+		{"all filters must match", Query{List: "home", Title: "bread"}, false},
+	}
+	for _, test := range tests {
+		if got := test.query.Match(item); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	//Filters on the due date never match an item without one
+	if (Query{DueAfter: &after}).Match(ToDoItem{Title: "no due"}) {
+		t.Error("an item without a due date matched DueAfter")
+	}
+}
+
+func TestFindSortAndPage(t *testing.T) {
+	todo := openTestDB(t)
+	early := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	late := early.AddDate(0, 0, 7)
+	for _, item := range []ToDoItem{
+		{Title: "b", Due: &late, Priority: PriorityLow},
+		{Title: "A", Priority: PriorityHigh},
+		{Title: "c", Due: &early, Priority: PriorityHigh},
+		{Title: "a", Due: &late},
+		{Title: "d", Priority: PriorityLow},
+	} {
+		if _, err := todo.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query Query
+		want  []int
+	}{
+		{Query{}, []int{1, 2, 3, 4, 5}},
+		//Ties keep to id order, whatever order the map hands them out in
+		{Query{SortBy: SortByDue}, []int{3, 1, 4, 2, 5}},
+		{Query{SortBy: SortByPriority}, []int{2, 3, 1, 5, 4}},
+		{Query{SortBy: SortByTitle}, []int{2, 4, 1, 3, 5}},
+		{Query{SortBy: SortByDue, Reverse: true}, []int{5, 2, 4, 1, 3}},
+		{Query{Offset: 1, Limit: 2}, []int{2, 3}},
+		{Query{SortBy: SortByDue, Offset: 3}, []int{2, 5}},
+		{Query{Offset: 5}, []int{}},
+		{Query{Limit: 10}, []int{1, 2, 3, 4, 5}},
+		{Query{Title: "a", SortBy: SortByTitle, Limit: 1}, []int{2}},
+	}
+	for _, test := range tests {
+		//Run each a few times, the map is iterated in a different order
+		//every time
+		for run := 0; run < 5; run++ {
+			items, err := todo.Find(test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]int, len(items))
+			for i, item := range items {
+				got[i] = item.Id
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%+v: got %v, want %v", test.query, got, test.want)
+				break
+			}
+		}
+	}
+
+	if _, err := todo.Find(Query{SortBy: "size"}); err == nil {
+		t.Error("Find accepted an invalid sort key")
+	}
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecurUnit is the unit a Recurrence counts in
+type RecurUnit string
+
+const (
+	RecurDay   RecurUnit = "day"
+	RecurWeek  RecurUnit = "week"
+	RecurMonth RecurUnit = "month"
+)
+
+// Recurrence is the rule of a repeating item, for example every 2 weeks
+// until the end of the year.  When an item with a rule is marked done a
+// new item is added for the next occurrence and the rule moves on to it,
+// the completed item stays behind as a normal done item.
+type Recurrence struct {
+	Every int       `json:"every"`
+	Unit  RecurUnit `json:"unit"`
+
+	// Until is the last day an occurrence may be due, nil repeats forever
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// recurNames are the words accepted by ParseRecurrence for a rule that
+// repeats every single unit
+var recurNames = map[string]RecurUnit{
+	"daily":   RecurDay,
+	"weekly":  RecurWeek,
+	"monthly": RecurMonth,
+}
+
+var (
+	recurEvery = regexp.MustCompile(`^every\s+(\d+)\s+(day|week|month)s?$`)
+	recurShort = regexp.MustCompile(`^\+?(\d+)([dwm])$`)
+)
+
+var recurShortUnits = map[string]RecurUnit{"d": RecurDay, "w": RecurWeek, "m": RecurMonth}
+
+// ParseRecurrence reads a rule written as daily, weekly or monthly,
+// "every N days" (or weeks or months) or in the short todo.txt form 3d,
+// 2w or 1m, optionally followed by "until" and a date.  "none" and the
+// empty string mean no rule and return nil.
+func ParseRecurrence(s string) (*Recurrence, error) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+
+	rule, until, hasUntil := strings.Cut(s, " until ")
+
+	var r Recurrence
+	if unit, ok := recurNames[rule]; ok {
+		r = Recurrence{Every: 1, Unit: unit}
+	} else if m := recurEvery.FindStringSubmatch(rule); m != nil {
+		r.Every, _ = strconv.Atoi(m[1])
+		r.Unit = RecurUnit(m[2])
+	} else if m := recurShort.FindStringSubmatch(rule); m != nil {
+		r.Every, _ = strconv.Atoi(m[1])
+		r.Unit = recurShortUnits[m[2]]
+	} else {
+		return nil, fmt.Errorf("invalid repeat rule %q, use daily, weekly, monthly or every N days/weeks/months", s)
+	}
+
+	if hasUntil {
+		date, err := ParseDate(until)
+		if err != nil {
+			return nil, err
+		}
+		r.Until = date
+	}
+
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// String writes the rule in a form ParseRecurrence reads back
+func (r Recurrence) String() string {
+	var rule string
+	switch {
+	case r.Every == 1 && r.Unit == RecurDay:
+		rule = "daily"
+	case r.Every == 1 && r.Unit == RecurWeek:
+		rule = "weekly"
+	case r.Every == 1 && r.Unit == RecurMonth:
+		rule = "monthly"
+	default:
+		rule = fmt.Sprintf("every %d %ss", r.Every, r.Unit)
+	}
+
+	if r.Until != nil {
+		rule += " until " + r.Until.Format("2006-01-02")
+	}
+
+	return rule
+}
+
+// Short writes the rule without its end date in the todo.txt form, for
+// example 2w
+func (r Recurrence) Short() string {
+	return strconv.Itoa(r.Every) + string(r.Unit)[:1]
+}
+
+// check makes sure a rule, which may have come straight from JSON, makes
+// sense
+func (r *Recurrence) check() error {
+	if r == nil {
+		return nil
+	}
+	if r.Every < 1 {
+		return fmt.Errorf("a repeat rule must repeat every 1 or more %ss", r.Unit)
+	}
+	if r.Unit != RecurDay && r.Unit != RecurWeek && r.Unit != RecurMonth {
+		return fmt.Errorf("invalid repeat unit %q, use day, week or month", r.Unit)
+	}
+
+	return nil
+}
+
+// after returns the date n occurrences after from.  A monthly rule that
+// starts on a day a later month does not have (the 31st, say) falls on
+// the last day of that month.
+func (r Recurrence) after(from time.Time, n int) time.Time {
+	switch r.Unit {
+	case RecurDay:
+		return from.AddDate(0, 0, n*r.Every)
+	case RecurWeek:
+		return from.AddDate(0, 0, 7*n*r.Every)
+	default:
+		year, month, day := from.Date()
+		first := time.Date(year, month+time.Month(n*r.Every), 1, from.Hour(), from.Minute(), from.Second(), 0, from.Location())
+		lastDay := first.AddDate(0, 1, -1).Day()
+		if day > lastDay {
+			day = lastDay
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+}
+
+// nextOccurrence builds the item that follows a recurring item which was
+// just completed, or returns nil if the rule has run out.  The next due
+// date counts on from the completed item's due date (or from when it was
+// completed, if it had none), skipping occurrences that are already past
+// so a chore that was done late is not immediately overdue again.
+func nextOccurrence(done ToDoItem, id int, now time.Time) *ToDoItem {
+	rule := done.Recur
+	if rule == nil {
+		return nil
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today
+	if done.Due != nil {
+		from = *done.Due
+	}
+
+	var due time.Time
+	for n := 1; n == 1 || due.Before(today); n++ {
+		due = rule.after(from, n)
+	}
+
+	if rule.Until != nil && !due.Before(rule.Until.AddDate(0, 0, 1)) {
+		return nil
+	}
+
+	next := done
+	next.Id = id
+	next.IsDone = false
+	next.Due = &due
+	next.Tags = append([]string(nil), done.Tags...)
+	next.BlockedBy = nil
+	next.CompletedAt = nil
+	next.ArchivedAt = nil
+	next.CreatedAt = now
+	next.UpdatedAt = now
+
+	return &next
+}
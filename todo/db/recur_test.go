@@ -0,0 +1,120 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"daily", "daily"},
+		{"Every 2 weeks", "every 2 weeks"},
+		{"3d", "every 3 days"},
+		{"+1m", "monthly"},
+		{"weekly until 2024-06-30", "weekly until 2024-06-30"},
+		{"none", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		r, err := ParseRecurrence(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+		got := ""
+		if r != nil {
+			got = r.String()
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+
+	for _, in := range []string{"yearly", "every 0 days", "2x", "daily until someday"} {
+		if _, err := ParseRecurrence(in); err == nil {
+			t.Errorf("%q: parsed a bad rule", in)
+		}
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	until := date(2024, 3, 31)
+	tests := []struct {
+		name string
+		rule Recurrence
+		due  time.Time // zero for an item without a due date
+		now  time.Time
+		want time.Time // zero when the rule ran out
+	}{
+		{"daily", Recurrence{Every: 1, Unit: RecurDay}, date(2024, 1, 10), date(2024, 1, 10), date(2024, 1, 11)},
+		{"every 2 weeks", Recurrence{Every: 2, Unit: RecurWeek}, date(2024, 1, 10), date(2024, 1, 10), date(2024, 1, 24)},
+		{"monthly at the end of the month", Recurrence{Every: 1, Unit: RecurMonth}, date(2024, 1, 31), date(2024, 1, 31), date(2024, 2, 29)},
+		{"done late skips past occurrences", Recurrence{Every: 1, Unit: RecurWeek}, date(2024, 1, 1), date(2024, 1, 20), date(2024, 1, 22)},
+		{"done early still moves on", Recurrence{Every: 1, Unit: RecurDay}, date(2024, 1, 10), date(2024, 1, 5), date(2024, 1, 11)},
+		{"no due date counts from today", Recurrence{Every: 3, Unit: RecurDay}, time.Time{}, date(2024, 1, 10), date(2024, 1, 13)},
+		{"last one before until", Recurrence{Every: 1, Unit: RecurMonth, Until: &until}, date(2024, 2, 29), date(2024, 2, 29), date(2024, 3, 29)},
+		{"past until", Recurrence{Every: 1, Unit: RecurMonth, Until: &until}, date(2024, 3, 29), date(2024, 3, 29), time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := test.rule
+			done := ToDoItem{Id: 1, Title: "chore", IsDone: true, Recur: &rule, Tags: []string{"home"}}
+			if !test.due.IsZero() {
+				done.Due = &test.due
+			}
+
+			next := nextOccurrence(done, 2, test.now)
+			if test.want.IsZero() {
+				if next != nil {
+					t.Fatalf("got an item due %v, want none", next.Due)
+				}
+				return
+			}
+			if next == nil {
+				t.Fatal("got no item")
+			}
+			if !next.Due.Equal(test.want) {
+				t.Errorf("got due %v, want %v", next.Due.Format(time.DateOnly), test.want.Format(time.DateOnly))
+			}
+			if next.Id != 2 || next.IsDone || next.CompletedAt != nil {
+				t.Errorf("got %+v, want a new open item", next)
+			}
+		})
+	}
+}
+
+// Marking a recurring item done adds the next one
+func TestRecurringItemDone(t *testing.T) {
+	todo := openTestDB(t)
+	due := time.Now().AddDate(0, 0, 1).Truncate(time.Second)
+	id, err := todo.AddItem(ToDoItem{Title: "water plants", Due: &due, Recur: &Recurrence{Every: 1, Unit: RecurWeek}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := todo.ChangeItemDoneStatus(id, true); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want the done one and the next", len(items))
+	}
+	if !items[0].IsDone || items[1].IsDone {
+		t.Errorf("got %+v, want the first done and the second open", items)
+	}
+	if want := due.AddDate(0, 0, 7); items[1].Due == nil || !items[1].Due.Equal(want) {
+		t.Errorf("got due %v, want %v", items[1].Due, want)
+	}
+}
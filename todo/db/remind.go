@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Reminders tell about items coming due and items that are overdue.
+// Each reminder is only sent once: the ones that were sent are recorded
+// in <db>.reminders next to the database, by item, kind and due date, so
+// moving the due date of an item arms its reminders again.
+
+// ReminderKind says why a reminder is sent
+type ReminderKind string
+
+const (
+	// ReminderUpcoming is sent ahead of the due date, see RemindOptions
+	ReminderUpcoming ReminderKind = "upcoming"
+	// ReminderOverdue is sent once the item is past due
+	ReminderOverdue ReminderKind = "overdue"
+)
+
+// Reminder is a reminder about one item
+type Reminder struct {
+	Kind ReminderKind `json:"kind"`
+	Item ToDoItem     `json:"item"`
+}
+
+// Message is the text of the reminder, for notifiers that show one line
+func (r Reminder) Message() string {
+	due := r.Item.Due.Local()
+	layout := "2006-01-02 15:04"
+	if due.Hour() == 0 && due.Minute() == 0 && due.Second() == 0 {
+		layout = "2006-01-02"
+	}
+
+	if r.Kind == ReminderOverdue {
+		return fmt.Sprintf("Overdue: %d %s (was due %s)", r.Item.Id, r.Item.Title, due.Format(layout))
+	}
+	return fmt.Sprintf("Due soon: %d %s (due %s)", r.Item.Id, r.Item.Title, due.Format(layout))
+}
+
+// RemindOptions says which reminders Reminders returns
+type RemindOptions struct {
+	// Filter picks the items reminded of, its sorting and paging are
+	// ignored.  Items that are done or archived are never reminded of.
+	Filter Query
+
+	// Lead is how long before the due date the upcoming reminder is
+	// sent.  A due date without a time is midnight at the start of the
+	// day, so with no lead the reminder comes on the morning of that day.
+	Lead time.Duration
+}
+
+// sentReminder records a reminder that was sent
+type sentReminder struct {
+	Id   int          `json:"id"`
+	Kind ReminderKind `json:"kind"`
+	Due  time.Time    `json:"due"`
+	Sent time.Time    `json:"sent"`
+}
+
+// reminderKey is what tells reminders apart in the record of sent ones
+type reminderKey struct {
+	id   int
+	kind ReminderKind
+	due  int64
+}
+
+func (r Reminder) key() reminderKey {
+	return reminderKey{r.Item.Id, r.Kind, r.Item.Due.Unix()}
+}
+
+func (s sentReminder) key() reminderKey {
+	return reminderKey{s.Id, s.Kind, s.Due.Unix()}
+}
+
+// Reminders returns the reminders that are due as of now and were not
+// sent yet, sorted by due date.  An item past due only gets the overdue
+// reminder, even if the upcoming one was never sent.  Call MarkReminded
+// once a reminder was sent so it is not returned again.
+func (t *ToDo) Reminders(opts RemindOptions, now time.Time) ([]Reminder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	sent, err := readSentReminders(t.remindersFileName())
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ToDoItem
+	for _, item := range t.toDoMap {
+		if item.Due != nil && !item.IsDone && item.ArchivedAt == nil && opts.Filter.Match(item) {
+			items = append(items, item)
+		}
+	}
+	sortByDue(items)
+
+	reminders := []Reminder{}
+	for _, item := range items {
+		var r Reminder
+		switch {
+		case !dueDeadline(*item.Due).After(now):
+			r = Reminder{Kind: ReminderOverdue, Item: item}
+		case !item.Due.Add(-opts.Lead).After(now):
+			r = Reminder{Kind: ReminderUpcoming, Item: item}
+		default:
+			continue
+		}
+		if _, ok := sent[r.key()]; !ok {
+			reminders = append(reminders, r)
+		}
+	}
+
+	return reminders, nil
+}
+
+// MarkReminded records that the reminders were sent at now.  The record
+// of items that are gone, done or archived is dropped at the same time.
+func (t *ToDo) MarkReminded(reminders []Reminder, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unlock, err := t.lockDB(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	fileName := t.remindersFileName()
+	sent, err := readSentReminders(fileName)
+	if err != nil {
+		return err
+	}
+	for _, r := range reminders {
+		sent[r.key()] = sentReminder{Id: r.Item.Id, Kind: r.Kind, Due: *r.Item.Due, Sent: now}
+	}
+
+	records := make([]sentReminder, 0, len(sent))
+	for _, s := range sent {
+		if item, ok := t.toDoMap[s.Id]; ok && !item.IsDone && item.ArchivedAt == nil {
+			records = append(records, s)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Id != records[j].Id {
+			return records[i].Id < records[j].Id
+		}
+		if !records[i].Due.Equal(records[j].Due) {
+			return records[i].Due.Before(records[j].Due)
+		}
+		return records[i].Kind < records[j].Kind
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data, journalPerm(t.journalSealer()))
+}
+
+// remindersFileName is the file the sent reminders are recorded in
+func (t *ToDo) remindersFileName() string {
+	return t.dbFileName + ".reminders"
+}
+
+// readSentReminders reads the record of sent reminders, a missing file
+// means none were sent
+func readSentReminders(fileName string) (map[reminderKey]sentReminder, error) {
+	sent := make(map[reminderKey]sentReminder)
+
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []sentReminder
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("reading sent reminders %s: %w", fileName, err)
+	}
+	for _, s := range records {
+		sent[s.key()] = s
+	}
+
+	return sent, nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatsPeriod is the length of the buckets Stats counts items in
+type StatsPeriod string
+
+const (
+	StatsDay  StatsPeriod = "day"
+	StatsWeek StatsPeriod = "week"
+)
+
+// ParseStatsPeriod checks that s names a valid StatsPeriod
+func ParseStatsPeriod(s string) (StatsPeriod, error) {
+	for _, period := range []StatsPeriod{StatsDay, StatsWeek} {
+		if strings.EqualFold(s, string(period)) {
+			return period, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid period %q, use day or week", s)
+}
+
+// StatsOptions says what Stats counts
+type StatsOptions struct {
+	// Filter picks the items counted, its sorting and paging are ignored.
+	// Archived items are counted unless the filter says otherwise, they
+	// are the history Stats is about.
+	Filter Query
+
+	Period StatsPeriod
+	// Since is the start of the first period, it is moved back to the
+	// start of its day or week.  The zero time starts at the oldest item.
+	Since time.Time
+}
+
+// PeriodStats counts the items of one day or week
+type PeriodStats struct {
+	Start     time.Time `json:"start"`
+	Created   int       `json:"created"`
+	Completed int       `json:"completed"`
+	// Open is the number of items open at the end of the period, or now
+	// for the current one, which is what a burndown chart plots
+	Open int `json:"open"`
+}
+
+// Stats sums up the items in the database
+type Stats struct {
+	Total    int `json:"total"`
+	Open     int `json:"open"`
+	Done     int `json:"done"`
+	Overdue  int `json:"overdue"`
+	Archived int `json:"archived"`
+
+	// AvgSecondsToDone is the mean time from created to completed of the
+	// done items, see AvgTimeToDone
+	AvgSecondsToDone float64 `json:"avgSecondsToDone"`
+
+	Period  StatsPeriod   `json:"period"`
+	Periods []PeriodStats `json:"periods"`
+}
+
+// AvgTimeToDone is the mean time it took to get an item done
+func (s Stats) AvgTimeToDone() time.Duration {
+	return time.Duration(s.AvgSecondsToDone * float64(time.Second))
+}
+
+// Stats counts the items matching opts.Filter, in total and per period
+// from opts.Since up to now.  Items that were deleted are gone from the
+// database and are not counted, archive items instead to keep them in
+// the numbers.
+func (t *ToDo) Stats(opts StatsOptions) (Stats, error) {
+	stats := Stats{Period: opts.Period}
+	if stats.Period == "" {
+		stats.Period = StatsDay
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadDB(); err != nil {
+		return stats, err
+	}
+
+	var items []ToDoItem
+	for _, item := range t.toDoMap {
+		if opts.Filter.Match(item) {
+			items = append(items, item)
+		}
+	}
+
+	now := time.Now()
+	var timeToDone time.Duration
+	var timed int
+	oldest := now
+	for _, item := range items {
+		stats.Total++
+		if item.ArchivedAt != nil {
+			stats.Archived++
+		}
+		if item.IsDone {
+			stats.Done++
+			if item.CompletedAt != nil {
+				timeToDone += item.CompletedAt.Sub(item.CreatedAt)
+				timed++
+			}
+		} else {
+			stats.Open++
+			if item.IsOverdue(now) {
+				stats.Overdue++
+			}
+		}
+		if item.CreatedAt.Before(oldest) {
+			oldest = item.CreatedAt
+		}
+	}
+	if timed > 0 {
+		stats.AvgSecondsToDone = timeToDone.Seconds() / float64(timed)
+	}
+
+	since := opts.Since
+	if since.IsZero() {
+		since = oldest
+	}
+	for start := periodStart(since, stats.Period); !start.After(now); start = nextPeriod(start, stats.Period) {
+		end := nextPeriod(start, stats.Period)
+		period := PeriodStats{Start: start}
+
+		for _, item := range items {
+			if inPeriod(item.CreatedAt, start, end) {
+				period.Created++
+			}
+			if item.IsDone && item.CompletedAt != nil && inPeriod(*item.CompletedAt, start, end) {
+				period.Completed++
+			}
+			if openAt(item, end) {
+				period.Open++
+			}
+		}
+
+		stats.Periods = append(stats.Periods, period)
+	}
+
+	return stats, nil
+}
+
+// periodStart is the start of the day or week, weeks start on Monday,
+// that t falls in
+func periodStart(t time.Time, period StatsPeriod) time.Time {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	if period != StatsWeek {
+		return day
+	}
+
+	sinceMonday := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -sinceMonday)
+}
+
+func nextPeriod(start time.Time, period StatsPeriod) time.Time {
+	if period == StatsWeek {
+		return start.AddDate(0, 0, 7)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
+
+func inPeriod(t, start, end time.Time) bool {
+	return !t.Before(start) && t.Before(end)
+}
+
+// openAt reports whether item was open just before t, as far as we can
+// tell: an item marked not done again has lost its completed time
+func openAt(item ToDoItem, t time.Time) bool {
+	if !item.CreatedAt.Before(t) {
+		return false
+	}
+
+	return !item.IsDone || item.CompletedAt == nil || !item.CompletedAt.Before(t)
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	//2024-05-15 is a Wednesday
+	wednesday := time.Date(2024, 5, 15, 17, 30, 0, 0, time.Local)
+	sunday := time.Date(2024, 5, 19, 23, 59, 0, 0, time.Local)
+	monday := time.Date(2024, 5, 13, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		t      time.Time
+		period StatsPeriod
+		want   time.Time
+	}{
+		{wednesday, StatsDay, time.Date(2024, 5, 15, 0, 0, 0, 0, time.Local)},
+		{wednesday, StatsWeek, monday},
+		{sunday, StatsWeek, monday},
+		{monday, StatsWeek, monday},
+	}
+	for _, test := range tests {
+		if got := periodStart(test.t, test.period); !got.Equal(test.want) {
+			t.Errorf("periodStart(%v, %s) = %v, want %v", test.t, test.period, got, test.want)
+		}
+	}
+
+	if got := nextPeriod(monday, StatsWeek); !got.Equal(monday.AddDate(0, 0, 7)) {
+		t.Errorf("nextPeriod of a week = %v, want the next Monday", got)
+	}
+}
+
+func TestOpenAt(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	completed := time.Date(2024, 5, 3, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		item ToDoItem
+		at   time.Time
+		want bool
+	}{
+		{"not created yet", ToDoItem{CreatedAt: created}, created, false},
+		{"open", ToDoItem{CreatedAt: created}, completed, true},
+		{"done later", ToDoItem{CreatedAt: created, IsDone: true, CompletedAt: &completed}, completed, true},
+		{"done before", ToDoItem{CreatedAt: created, IsDone: true, CompletedAt: &completed}, completed.Add(time.Second), false},
+		{"done without a time", ToDoItem{CreatedAt: created, IsDone: true}, completed, true},
+	}
+	for _, test := range tests {
+		if got := openAt(test.item, test.at); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	todo := openTestDB(t)
+
+	now := time.Now()
+	today := periodStart(now, StatsDay)
+	yesterday := today.AddDate(0, 0, -1)
+	twoDaysAgo := today.AddDate(0, 0, -2)
+	at := func(day time.Time) *time.Time {
+		t := day.Add(12 * time.Hour)
+		return &t
+	}
+
+	//Saved straight to the store so the timestamps are not stamped over
+	items := []ToDoItem{
+		{Id: 1, Title: "done yesterday", IsDone: true, CreatedAt: *at(twoDaysAgo), CompletedAt: at(yesterday)},
+		{Id: 2, Title: "due today", Due: &today, CreatedAt: *at(twoDaysAgo)},
+		{Id: 3, Title: "due yesterday", Due: &yesterday, CreatedAt: *at(yesterday)},
+		{Id: 4, Title: "archived", IsDone: true, CreatedAt: *at(twoDaysAgo), CompletedAt: at(twoDaysAgo), ArchivedAt: at(yesterday)},
+	}
+	for i := range items {
+		items[i].List = DefaultList
+		items[i].UpdatedAt = items[i].CreatedAt
+	}
+	if err := todo.store.Save(Meta{Seq: len(items)}, items, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := todo.Stats(StatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 4 || stats.Open != 2 || stats.Done != 2 || stats.Archived != 1 {
+		t.Errorf("got %+v, want 4 items, 2 open, 2 done and 1 archived", stats)
+	}
+	//Due today without a time is not overdue yet
+	if stats.Overdue != 1 {
+		t.Errorf("got %d overdue, want 1", stats.Overdue)
+	}
+	if want := items[0].CompletedAt.Sub(items[0].CreatedAt) / 2; stats.AvgTimeToDone() != want {
+		t.Errorf("got an average time to done of %v, want %v", stats.AvgTimeToDone(), want)
+	}
+
+	want := []PeriodStats{
+		{Start: twoDaysAgo, Created: 3, Completed: 1, Open: 2},
+		{Start: yesterday, Created: 1, Completed: 1, Open: 2},
+		{Start: today, Open: 2},
+	}
+	if len(stats.Periods) != len(want) {
+		t.Fatalf("got %+v, want %+v", stats.Periods, want)
+	}
+	for i := range want {
+		if got := stats.Periods[i]; !got.Start.Equal(want[i].Start) || got.Created != want[i].Created ||
+			got.Completed != want[i].Completed || got.Open != want[i].Open {
+			t.Errorf("period %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	//A week bucket holds all of it, or two if the week started in between
+	stats, err = todo.Stats(StatsOptions{Period: StatsWeek})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := 0
+	for _, p := range stats.Periods {
+		created += p.Created
+		if p.Start.Weekday() != time.Monday {
+			t.Errorf("week starts on %v, want Monday", p.Start.Weekday())
+		}
+	}
+	if created != 4 || len(stats.Periods) > 2 {
+		t.Errorf("got %+v, want the 4 items created in one or two weeks", stats.Periods)
+	}
+}
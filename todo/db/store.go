@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Store is the persistence layer that sits behind a ToDo.  The ToDo
+// struct keeps its items in memory in a DbMap, and uses a Store to
+// read them from, and write them back to, wherever they actually live.
+//
+// Save is given only the items that changed rather than the whole map
+// so that backends that can update a single record in place (like the
+// key-value store) do not have to rewrite everything on every AddItem
+// or UpdateItem.
+type Store interface {
+	// Load returns every item currently held by the store, along with
+	// the database wide values kept next to them
+	Load() (DbMap, Meta, error)
+
+	// Save stores meta, inserts or replaces every item in puts, and
+	// removes every item whose id is in deletes
+	Save(meta Meta, puts []ToDoItem, deletes []int) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Meta holds the database wide values a Store keeps next to the items
+type Meta struct {
+	// Seq is the last id handed out to a new item.  It only ever grows,
+	// so the id of a deleted item is never given out again.
+	Seq int `json:"seq"`
+
+	// Rev is the revision of the store the values were read at, for
+	// stores that write their file in place (see revStore).  It is not
+	// saved, the store keeps track of it on its own.
+	Rev uint64 `json:"-"`
+}
+
+// revStore is implemented by stores that write their file in place, so
+// the file can change without its size or modification time changing.
+// The revision the map was loaded at is compared before trusting it.
+type revStore interface {
+	// rev returns the revision of the last write to the store
+	rev() (uint64, error)
+}
+
+// The URI schemes accepted by the --db flag.  A plain file name with no
+// scheme is treated as a JSON file so existing databases keep working.
+const (
+	JSONScheme = "json"
+	BoltScheme = "bolt"
+)
+
+// OpenStore parses a database URI of the form scheme://path and returns
+// the matching Store.  If the database does not exist yet the backend
+// will create an empty one.
+//
+// Examples:
+//
+//	./data/todo.json          JSON array file (the default)
+//	json://./data/todo.json   same as above
+//	bolt://./data/todo.db     embedded key-value store
+func OpenStore(dbURI string) (Store, string, error) {
+	scheme, path := ParseDbURI(dbURI)
+
+	switch scheme {
+	case JSONScheme:
+		s, err := newJSONStore(path)
+		return s, path, err
+	case BoltScheme:
+		s, err := newBoltStore(path)
+		return s, path, err
+	default:
+		return nil, path, fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+}
+
+// ParseDbURI splits a database URI into its scheme and file path.
+// Names without a scheme default to the JSON backend.
+func ParseDbURI(dbURI string) (scheme string, path string) {
+	if scheme, path, ok := strings.Cut(dbURI, "://"); ok {
+		return strings.ToLower(scheme), path
+	}
+
+	return JSONScheme, dbURI
+}
@@ -0,0 +1,585 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sync merges two copies of a database that were changed independently,
+// for example one on a laptop and one on a desktop, and writes the merged
+// items to both.
+//
+// The merge is three-way.  After every sync each side keeps a copy of the
+// merged items, the sync base, in <db>.sync/ next to the database.  The
+// next sync compares both sides with their common base field by field: a
+// field changed on one side only takes that side's value, a field changed
+// on both sides to different values is a Conflict, settled by the
+// SyncPolicy.  Without a base (the first sync between two files) every
+// field that differs is a conflict.
+//
+// Items are matched by id and created time.  Ids are only unique within
+// one database, so an item added on both sides under the same id is two
+// items, and the other side's one is renumbered.
+
+// SyncPolicy says how Sync settles a conflict
+type SyncPolicy string
+
+const (
+	// SyncNewer takes the value of the side whose item was updated last
+	SyncNewer SyncPolicy = "newer"
+	// SyncOurs takes the value of the database Sync is called on
+	SyncOurs SyncPolicy = "ours"
+	// SyncTheirs takes the value of the other database
+	SyncTheirs SyncPolicy = "theirs"
+	// SyncFail refuses the whole sync with ErrSyncConflict
+	SyncFail SyncPolicy = "fail"
+)
+
+// ParseSyncPolicy checks that s names a valid SyncPolicy
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	for _, policy := range []SyncPolicy{SyncNewer, SyncOurs, SyncTheirs, SyncFail} {
+		if strings.EqualFold(s, string(policy)) {
+			return policy, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid sync policy %q, use newer, ours, theirs or fail", s)
+}
+
+// The sides of a sync, as named in a Conflict
+const (
+	SideOurs   = "ours"
+	SideTheirs = "theirs"
+)
+
+// Conflict is a field of an item that was changed differently on both
+// sides.  Ours and Theirs are the two values in JSON, Field "deleted"
+// means one side deleted the item while the other changed it.
+type Conflict struct {
+	Id     int    `json:"id"`
+	Field  string `json:"field"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+	// Winner is the side whose value was kept, SideOurs or SideTheirs,
+	// empty when the policy is SyncFail
+	Winner string `json:"winner,omitempty"`
+}
+
+// SyncReport says what Sync did, or would do on a dry run
+type SyncReport struct {
+	// Pulled are the ids of the items changed in this database, Pushed
+	// those changed in the other one
+	Pulled []int `json:"pulled"`
+	Pushed []int `json:"pushed"`
+	// Renumbered are the other side's items that were added on both sides
+	// under the same id
+	Renumbered []Renumbered `json:"renumbered"`
+	Conflicts  []Conflict   `json:"conflicts"`
+	// Fixed are problems the merge left, like a subtask whose parent was
+	// deleted on the other side, and how they were fixed
+	Fixed []Problem `json:"fixed"`
+	// FirstSync is set when there was no sync base to merge from
+	FirstSync bool `json:"firstSync"`
+	DryRun    bool `json:"dryRun"`
+}
+
+// SyncOptions controls Sync
+type SyncOptions struct {
+	Policy SyncPolicy
+	// DryRun works out the report without changing either database
+	DryRun bool
+}
+
+// errSyncDryRun is returned from the batches of a dry run to throw their
+// changes away
+var errSyncDryRun = errors.New("dry run")
+
+// Sync merges this database with other, see the top of this file, and
+// saves the merged items to both.  Each side records the sync as a single
+// entry in its own journal, so it can be undone on either side.
+//
+// Conflicts are settled by opts.Policy.  With SyncFail any conflict
+// refuses the sync with ErrSyncConflict, and the report lists them.
+func (t *ToDo) Sync(other *ToDo, opts SyncOptions) (SyncReport, error) {
+	report := SyncReport{DryRun: opts.DryRun}
+	if opts.Policy == "" {
+		opts.Policy = SyncNewer
+	}
+
+	ourName, err := filepath.Abs(t.dbFileName)
+	if err != nil {
+		return report, err
+	}
+	theirName, err := filepath.Abs(other.dbFileName)
+	if err != nil {
+		return report, err
+	}
+	if ourName == theirName {
+		return report, errors.New("cannot sync a database with itself")
+	}
+
+	var base *syncBase
+	var ourSealer, theirSealer *sealer
+	err = t.batch(context.Background(), OpSync, func(ours *Batch) error {
+		return other.batch(context.Background(), OpSync, func(theirs *Batch) error {
+			//Both databases are loaded now, so we know their keys
+			ourSealer = t.journalSealer()
+			theirSealer = other.journalSealer()
+
+			prev, err := readSyncBase(syncBaseName(t.dbFileName, theirName), ourSealer)
+			if err != nil {
+				return err
+			}
+			report.FirstSync = prev == nil
+
+			base = mergeItems(prev, ours, theirs, opts.Policy, &report)
+			if opts.Policy == SyncFail && len(report.Conflicts) > 0 {
+				return fmt.Errorf("%w to %d fields, nothing was changed", ErrSyncConflict, len(report.Conflicts))
+			}
+
+			report.Pulled = applySync(ours, base)
+			report.Pushed = applySync(theirs, base)
+			if opts.DryRun {
+				return errSyncDryRun
+			}
+			return nil
+		})
+	})
+	if errors.Is(err, errSyncDryRun) {
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+
+	//Both sides hold the merged items now, which makes them the base of
+	//the next sync.  If this fails the next sync merges from the older
+	//base, which still gives the right result.
+	if err := writeSyncBase(syncBaseName(t.dbFileName, theirName), base, ourSealer); err != nil {
+		return report, fmt.Errorf("the databases were synced but the sync base could not be saved: %w", err)
+	}
+	if err := writeSyncBase(syncBaseName(other.dbFileName, ourName), base, theirSealer); err != nil {
+		return report, fmt.Errorf("the databases were synced but the sync base could not be saved: %w", err)
+	}
+
+	return report, nil
+}
+
+// syncBase is the merged content of both sides as of the last sync
+type syncBase struct {
+	meta  Meta
+	items DbMap
+}
+
+// mergeItems works out the merged items of both sides, filling in the
+// conflicts, renumbered items and fixes of report
+func mergeItems(prev *syncBase, ours, theirs *Batch, policy SyncPolicy, report *SyncReport) *syncBase {
+	baseItems := DbMap{}
+	if prev != nil {
+		baseItems = prev.items
+	}
+
+	seq := ours.meta.Seq
+	if theirs.meta.Seq > seq {
+		seq = theirs.meta.Seq
+	}
+	seq = nextId(seq, ours.items) - 1
+	seq = nextId(seq, theirs.items) - 1
+
+	//Items added on both sides under the same id are two items, give
+	//theirs a new id and point their links at it
+	theirItems := make(DbMap, len(theirs.items))
+	newIds := make(map[int]int)
+	for _, item := range theirs.Items() {
+		id := item.Id
+		if our, ok := ours.items[id]; ok && !sameAdd(prev, &our, &item) {
+			seq++
+			newIds[id] = seq
+			report.Renumbered = append(report.Renumbered, Renumbered{From: id, To: seq})
+			item.Id = seq
+		}
+		theirItems[item.Id] = item
+	}
+	for id, item := range theirItems {
+		if newId, ok := newIds[item.ParentId]; ok {
+			item.ParentId = newId
+		}
+		if len(newIds) > 0 && len(item.BlockedBy) > 0 {
+			blockers := make([]int, len(item.BlockedBy))
+			for i, blocker := range item.BlockedBy {
+				if newId, ok := newIds[blocker]; ok {
+					blocker = newId
+				}
+				blockers[i] = blocker
+			}
+			item.BlockedBy = blockers
+			cleanBlockedBy(&item)
+		}
+		theirItems[id] = item
+	}
+	ids := make(map[int]bool)
+	for _, items := range []DbMap{baseItems, ours.items, theirItems} {
+		for id := range items {
+			ids[id] = true
+		}
+	}
+	order := make([]int, 0, len(ids))
+	for id := range ids {
+		order = append(order, id)
+	}
+	sort.Ints(order)
+
+	var merged []scannedItem
+	for _, id := range order {
+		our, hasOurs := ours.items[id]
+		their, hasTheirs := theirItems[id]
+		base, hasBase := baseItems[id]
+
+		//A base only counts for the side whose item it is
+		ourBase, theirBase := optionalItem(base, hasBase), optionalItem(base, hasBase)
+		if hasOurs && !sameOrigin(ourBase, &our) {
+			ourBase = nil
+		}
+		if hasTheirs && !sameOrigin(theirBase, &their) {
+			theirBase = nil
+		}
+
+		item := mergeItem(ourBase, theirBase, optionalItem(our, hasOurs), optionalItem(their, hasTheirs), policy, report)
+		if item != nil {
+			merged = append(merged, scannedItem{item: *item})
+		}
+	}
+
+	//Changes that merged cleanly on their own can still clash, a subtask
+	//added on one side under a parent deleted on the other.  The checks
+	//of fsck find and fix those.
+	meta, fixed, problems := checkItems(fsckScan{
+		items:    merged,
+		meta:     Meta{Seq: seq},
+		fileTime: time.Now(),
+	})
+	report.Fixed = problems
+
+	items := make(DbMap, len(fixed))
+	for _, item := range fixed {
+		items[item.Id] = item
+	}
+	return &syncBase{meta: meta, items: items}
+}
+
+// mergeItem merges one item, ourBase and theirBase are the base for each
+// side (nil if that side's item was added since the last sync) and our and
+// their are the items (nil if deleted or never there).  It returns nil if
+// the item is gone.
+func mergeItem(ourBase, theirBase, our, their *ToDoItem, policy SyncPolicy, report *SyncReport) *ToDoItem {
+	switch {
+	case our == nil && their == nil:
+		return nil
+
+	case their == nil:
+		//Deleted on their side, or added on ours
+		if theirBase == nil || ourBase == nil {
+			return our
+		}
+		if sameItem(ourBase, our) {
+			return nil
+		}
+		return deleteConflict(our, our, nil, policy, report)
+
+	case our == nil:
+		if ourBase == nil || theirBase == nil {
+			return their
+		}
+		if sameItem(theirBase, their) {
+			return nil
+		}
+		return deleteConflict(their, nil, their, policy, report)
+	}
+
+	//The same item on both sides, merge it field by field
+	base := ourBase
+	if theirBase == nil {
+		base = nil
+	}
+
+	item := *our
+	for _, field := range syncFields {
+		ourValue, theirValue := field.value(our), field.value(their)
+		if ourValue == theirValue {
+			continue
+		}
+
+		var baseValue string
+		if base != nil {
+			baseValue = field.value(base)
+		}
+		switch {
+		case base != nil && ourValue == baseValue:
+			field.copy(&item, their)
+		case base != nil && theirValue == baseValue:
+			//Ours already has it
+		default:
+			winner := conflictWinner(our, their, policy)
+			report.Conflicts = append(report.Conflicts, Conflict{
+				Id: our.Id, Field: field.name, Ours: ourValue, Theirs: theirValue, Winner: winner,
+			})
+			if winner == SideTheirs {
+				field.copy(&item, their)
+			}
+		}
+	}
+
+	//Both sides end up with the same item, updated when the latest change
+	//to it was made
+	if their.UpdatedAt.After(item.UpdatedAt) {
+		item.UpdatedAt = their.UpdatedAt
+	}
+	return &item
+}
+
+// deleteConflict settles an item deleted on one side and changed on the
+// other, our or their is nil for the side that deleted it.  Keeping the
+// change is the safe choice, so that is what SyncNewer does.
+func deleteConflict(item, our, their *ToDoItem, policy SyncPolicy, report *SyncReport) *ToDoItem {
+	conflict := Conflict{Id: item.Id, Field: "deleted", Ours: "changed", Theirs: "changed"}
+	keep := SideOurs
+	if our == nil {
+		conflict.Ours = "deleted"
+		keep = SideTheirs
+	} else {
+		conflict.Theirs = "deleted"
+	}
+
+	switch policy {
+	case SyncNewer:
+		conflict.Winner = keep
+	case SyncOurs, SyncTheirs:
+		conflict.Winner = string(policy)
+	}
+	report.Conflicts = append(report.Conflicts, conflict)
+
+	if conflict.Winner == keep {
+		return item
+	}
+	return nil
+}
+
+// conflictWinner picks the side whose value is kept
+func conflictWinner(our, their *ToDoItem, policy SyncPolicy) string {
+	switch policy {
+	case SyncOurs:
+		return SideOurs
+	case SyncTheirs:
+		return SideTheirs
+	case SyncNewer:
+		if their.UpdatedAt.After(our.UpdatedAt) {
+			return SideTheirs
+		}
+		return SideOurs
+	}
+
+	return ""
+}
+
+// sameOrigin reports whether two items with the same id are the same
+// item, rather than two items added separately on each side.  A nil item
+// matches nothing.
+func sameOrigin(a, b *ToDoItem) bool {
+	return a != nil && b != nil && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+// sameAdd reports whether the items under the same id on both sides are
+// the same item.  Ids are handed out separately on each side, so once
+// there is a base an id that is not in it was added on both sides since,
+// as two items.  Without a base the created time is all we have.
+func sameAdd(prev *syncBase, our, their *ToDoItem) bool {
+	if prev == nil {
+		return sameOrigin(our, their)
+	}
+
+	base, ok := prev.items[our.Id]
+	if !ok {
+		//Unless the last sync could not save its base
+		return sameItem(our, their)
+	}
+	return sameOrigin(&base, our) && sameOrigin(&base, their)
+}
+
+func optionalItem(item ToDoItem, ok bool) *ToDoItem {
+	if !ok {
+		return nil
+	}
+
+	return &item
+}
+
+// syncField is a part of an item that is merged as a whole.  Fields that
+// only make sense together, like done and the completed time, are one
+// syncField.
+type syncField struct {
+	name string
+	get  func(item *ToDoItem) any
+	copy func(dst, src *ToDoItem)
+}
+
+// value is the field in JSON, so fields can be compared and shown
+func (f syncField) value(item *ToDoItem) string {
+	data, err := json.Marshal(f.get(item))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+var syncFields = []syncField{
+	{"title", func(i *ToDoItem) any { return i.Title }, func(d, s *ToDoItem) { d.Title = s.Title }},
+	{"list", func(i *ToDoItem) any { return i.List }, func(d, s *ToDoItem) { d.List = s.List }},
+	{"done", func(i *ToDoItem) any { return []any{i.IsDone, i.CompletedAt} }, func(d, s *ToDoItem) {
+		d.IsDone, d.CompletedAt = s.IsDone, s.CompletedAt
+	}},
+	{"due", func(i *ToDoItem) any { return i.Due }, func(d, s *ToDoItem) { d.Due = s.Due }},
+	{"recur", func(i *ToDoItem) any { return i.Recur }, func(d, s *ToDoItem) { d.Recur = s.Recur }},
+	{"priority", func(i *ToDoItem) any { return i.Priority }, func(d, s *ToDoItem) { d.Priority = s.Priority }},
+	{"tags", func(i *ToDoItem) any { return i.Tags }, func(d, s *ToDoItem) { d.Tags = s.Tags }},
+	{"notes", func(i *ToDoItem) any { return i.Notes }, func(d, s *ToDoItem) { d.Notes = s.Notes }},
+	{"parent", func(i *ToDoItem) any { return i.ParentId }, func(d, s *ToDoItem) { d.ParentId = s.ParentId }},
+	{"blockedBy", func(i *ToDoItem) any { return i.BlockedBy }, func(d, s *ToDoItem) { d.BlockedBy = s.BlockedBy }},
+	{"archived", func(i *ToDoItem) any { return i.ArchivedAt }, func(d, s *ToDoItem) { d.ArchivedAt = s.ArchivedAt }},
+}
+
+// applySync changes the items of a batch to the merged ones and returns
+// the ids of the items that changed
+func applySync(b *Batch, merged *syncBase) []int {
+	var changed []int
+	for id := range b.items {
+		if _, ok := merged.items[id]; !ok {
+			b.remove(id)
+			changed = append(changed, id)
+		}
+	}
+	for id, item := range merged.items {
+		old, ok := b.items[id]
+		if ok && sameItem(&old, &item) {
+			continue
+		}
+		b.put(item)
+		changed = append(changed, id)
+	}
+
+	if merged.meta.Seq > b.meta.Seq {
+		b.meta.Seq = merged.meta.Seq
+	}
+
+	sort.Ints(changed)
+	return changed
+}
+
+// syncBaseName is the file the base for syncing dbFileName with the
+// database at otherPath is kept in
+func syncBaseName(dbFileName, otherPath string) string {
+	sum := sha256.Sum256([]byte(otherPath))
+	return filepath.Join(dbFileName+".sync", hex.EncodeToString(sum[:8])+".json")
+}
+
+// readSyncBase reads a sync base, nil if there is none.  A base that
+// cannot be read, for example because the database was rekeyed since, is
+// no base at all: the sync falls back to treating every difference as a
+// conflict, which is always safe.
+func readSyncBase(fileName string, s *sealer) (*syncBase, error) {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//A plain base next to an encrypted database is not trusted, anyone
+	//who can write the file could have put it there
+	if file, ok := parseEncrypted(data); ok {
+		if s == nil || !s.sameKey(file.Encryption) {
+			return nil, nil
+		}
+		if data, err = s.openFile(file); err != nil {
+			return nil, nil
+		}
+	} else if s != nil {
+		return nil, nil
+	}
+
+	var file jsonFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil
+	}
+
+	base := &syncBase{meta: Meta{Seq: file.Seq}, items: make(DbMap, len(file.Items))}
+	for _, item := range file.Items {
+		base.items[item.Id] = item
+	}
+	return base, nil
+}
+
+// resealSyncBases writes the sync bases of the database again sealed with
+// next instead of old, nil for either means plain text, so Encrypt does
+// not leave the items in a plain base.  A base that cannot be read or
+// written again is deleted, which only makes the next sync with that
+// database treat every difference as a conflict.
+func (t *ToDo) resealSyncBases(old, next *sealer) error {
+	names, err := filepath.Glob(filepath.Join(t.dbFileName+".sync", "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		base, err := readSyncBase(name, old)
+		if err == nil && base != nil {
+			if err = writeSyncBase(name, base, next); err == nil {
+				continue
+			}
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("sync base %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeSyncBase saves a sync base, encrypted with s if it is not nil so
+// the base of an encrypted database does not give its items away
+func writeSyncBase(fileName string, base *syncBase, s *sealer) error {
+	file := jsonFile{Version: SchemaVersion, Seq: base.meta.Seq, Items: make([]ToDoItem, 0, len(base.items))}
+	for _, item := range base.items {
+		file.Items = append(file.Items, item)
+	}
+	sort.Slice(file.Items, func(i, j int) bool {
+		return file.Items[i].Id < file.Items[j].Id
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	if s != nil {
+		if data, err = s.sealFile(data); err != nil {
+			return err
+		}
+		perm = 0600
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data, perm)
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// syncedPair returns two databases synced once, both holding items 1
+// and 2, and then changed independently: both sides renamed item 1,
+// ours deleted item 2 while theirs raised its priority, and theirs added
+// an item 3.
+func syncedPair(t *testing.T) (ours, theirs *ToDo) {
+	t.Helper()
+
+	dir := t.TempDir()
+	ours = openDB(t, filepath.Join(dir, "ours.json"))
+	theirs = openDB(t, filepath.Join(dir, "theirs.json"))
+	addItems(t, ours, "a", "b")
+
+	report, err := ours.Sync(theirs, SyncOptions{Policy: SyncFail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.FirstSync || len(report.Pushed) != 2 {
+		t.Fatalf("got %+v, want a first sync pushing both items", report)
+	}
+
+	if err := ours.UpdateItem(ToDoItem{Id: 1, Title: "our a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ours.DeleteItem(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := theirs.UpdateItem(ToDoItem{Id: 1, Title: "their a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := theirs.UpdateItem(ToDoItem{Id: 2, Title: "b", Priority: PriorityHigh}); err != nil {
+		t.Fatal(err)
+	}
+	addItems(t, theirs, "c")
+
+	return ours, theirs
+}
+
+// sameTitles checks that both databases hold items with these titles
+func sameTitles(t *testing.T, ours, theirs *ToDo, want ...string) {
+	t.Helper()
+
+	for _, todo := range []*ToDo{ours, theirs} {
+		items, err := todo.GetAllItems()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, item := range items {
+			got = append(got, item.Title)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", filepath.Base(todo.dbFileName), got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: got %v, want %v", filepath.Base(todo.dbFileName), got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestSyncPolicies(t *testing.T) {
+	tests := []struct {
+		policy SyncPolicy
+		titles []string
+	}{
+		//Keeping the change beats the delete, and the newer rename is
+		//the one made by theirs
+		{SyncNewer, []string{"their a", "b", "c"}},
+		{SyncOurs, []string{"our a", "c"}},
+		{SyncTheirs, []string{"their a", "b", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.policy), func(t *testing.T) {
+			ours, theirs := syncedPair(t)
+			if test.policy == SyncNewer {
+				//Updates are stamped to the second, make sure theirs is
+				//the newer one
+				item, err := theirs.GetItem(1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				item.UpdatedAt = item.UpdatedAt.Add(2 * time.Second)
+				if err := theirs.store.Save(theirs.meta, []ToDoItem{item}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := ours.Sync(theirs, SyncOptions{Policy: test.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.FirstSync {
+				t.Error("the sync base of the first sync was not used")
+			}
+			if len(report.Conflicts) != 2 {
+				t.Errorf("got conflicts %+v, want the rename and the delete", report.Conflicts)
+			}
+			sameTitles(t, ours, theirs, test.titles...)
+
+			//A field only one side changed is not a conflict
+			if item, err := ours.GetItem(2); err == nil && item.Priority != PriorityHigh {
+				t.Errorf("got priority %v, want the one set by theirs", item.Priority)
+			}
+
+			//Nothing is left to merge after a sync
+			report, err = ours.Sync(theirs, SyncOptions{Policy: SyncFail})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Pulled)+len(report.Pushed)+len(report.Conflicts) != 0 {
+				t.Errorf("got %+v syncing again, want nothing to do", report)
+			}
+		})
+	}
+}
+
+func TestSyncFail(t *testing.T) {
+	ours, theirs := syncedPair(t)
+
+	report, err := ours.Sync(theirs, SyncOptions{Policy: SyncFail})
+	if !errors.Is(err, ErrSyncConflict) {
+		t.Fatalf("got %v, want ErrSyncConflict", err)
+	}
+	fields := make(map[string]bool)
+	for _, c := range report.Conflicts {
+		fields[c.Field] = true
+		if c.Winner != "" {
+			t.Errorf("conflict %+v has a winner", c)
+		}
+	}
+	if !fields["title"] || !fields["deleted"] {
+		t.Errorf("got conflicts %+v, want the title and the delete", report.Conflicts)
+	}
+
+	//Neither side changed
+	sameTitles(t, ours, openDB(t, ours.dbFileName), "our a")
+	sameTitles(t, theirs, openDB(t, theirs.dbFileName), "their a", "b", "c")
+}
+
+func TestSyncDryRun(t *testing.T) {
+	ours, theirs := syncedPair(t)
+
+	report, err := ours.Sync(theirs, SyncOptions{Policy: SyncTheirs, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Pulled) == 0 {
+		t.Errorf("got %+v, want a dry run pulling items", report)
+	}
+	sameTitles(t, ours, openDB(t, ours.dbFileName), "our a")
+}
@@ -1,17 +1,42 @@
 package db
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
 // ToDoItem is the struct that represents a single ToDo item
+//
+// The timestamps are maintained by the db package: CreatedAt is set by
+// AddItem, UpdatedAt on every change, and CompletedAt whenever the item
+// is marked done (and cleared again when it is marked not done).  Values
+// set by the caller are ignored.
+//
+// ParentId makes the item a subtask of another item, and BlockedBy lists
+// items that have to be done first.  See deps.go for the rules they
+// follow.  Recur makes the item repeat, see Recurrence.  List names the
+// list the item is on, items added without one go on DefaultList.
+// ArchivedAt is set while a done item is in the archive, see archive.go.
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id          int         `json:"id"`
+	Title       string      `json:"title"`
+	List        string      `json:"list"`
+	IsDone      bool        `json:"done"`
+	Due         *time.Time  `json:"due,omitempty"`
+	Recur       *Recurrence `json:"recur,omitempty"`
+	Priority    Priority    `json:"priority,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Notes       string      `json:"notes,omitempty"`
+	ParentId    int         `json:"parent,omitempty"`
+	BlockedBy   []int       `json:"blockedBy,omitempty"`
+	CreatedAt   time.Time   `json:"created"`
+	UpdatedAt   time.Time   `json:"updated"`
+	CompletedAt *time.Time  `json:"completed,omitempty"`
+	ArchivedAt  *time.Time  `json:"archived,omitempty"`
 }
 
 // DbMap is a type alias for a map of ToDoItems.  The key
@@ -19,8 +44,9 @@ type ToDoItem struct {
 type DbMap map[int]ToDoItem
 
 // ToDo is the struct that represents the main object of our
-// todo app.  It contains a map of ToDoItems and the name of
-// the file that is used to store the items.
+// todo app.  It contains a map of ToDoItems, the name of
+// the file that is used to store the items and the Store
+// backend that reads and writes that file.
 //
 // TODO: Notice how the fields in the struct are not exported
 //
@@ -30,35 +56,57 @@ type DbMap map[int]ToDoItem
 // ANSWER: The main code should not be able to modify the toDoMap as this would
 // cause errors in future database retrieval and storage. This and the database file name
 // should only be editable by the db package and not elswehere.
+//
+// A ToDo is safe for concurrent use by multiple goroutines, so a long
+// running service can share a single one between all of its requests.
+// Changes made through it are also safe against other processes using
+// the same database, see lockDB.  Functions ending in Context take a
+// context.Context that can cancel the wait for the database lock, see
+// AddItemContext.
 type ToDo struct {
-	toDoMap    DbMap
-	dbFileName string
+	toDoMap     DbMap
+	meta        Meta
+	dbFileName  string
+	store       Store
+	lockTimeout time.Duration
+
+	// stamp identifies the version of the database file that toDoMap
+	// was loaded from, so we only re-read the file when it changes
+	stamp *fileStamp
+
+	// hooks are called for the changes in pendingHooks once the change is
+	// saved and the database unlocked, see runHooks
+	hooks        []hook
+	pendingHooks []HookPayload
+
+	// mu guards toDoMap, meta, stamp, lockTimeout and the hooks, which the
+	// goroutine started by Watch updates behind the caller's back
+	mu sync.Mutex
 }
 
 // New is a constructor function that returns a pointer to a new
-// ToDo struct.  It takes a single string argument that is the
-// name of the file that will be used to store the ToDo items.
-// If the file doesn't exist, it will be created.  If the file
-// does exist, it will be loaded into the ToDo struct.
+// ToDo struct.  It takes a single string argument that names the
+// database used to store the ToDo items.  The name may be prefixed with
+// a scheme (see OpenStore) to pick the storage backend, a plain file
+// name uses the JSON file backend.  If the database doesn't exist, it
+// will be created.  If it does exist, it will be loaded into the ToDo
+// struct.
 func New(dbFile string) (*ToDo, error) {
 
-	//Check if the database file exists, if not use initDB to create it
-	//In go, you use the os.Stat function to get information about a file
-	//In this case, we are only checking the error, because if we get an
-	//error we can safely assume that this file does not exist.
-	if _, err := os.Stat(dbFile); err != nil {
-		//If the file doesn't exist, create it
-		err := initDB(dbFile)
-		if err != nil {
-			return nil, err
-		}
+	//Open the storage backend named by the db URI, the backend takes
+	//care of creating an empty database if one does not exist yet
+	store, dbFileName, err := OpenStore(dbFile)
+	if err != nil {
+		return nil, err
 	}
 
-	//Now that we know the file exists, at at the minimum we have
+	//Now that we know the database exists, at at the minimum we have
 	//a valid empty DB, lets create the ToDo struct
 	toDo := &ToDo{
-		toDoMap:    make(map[int]ToDoItem),
-		dbFileName: dbFile,
+		toDoMap:     make(map[int]ToDoItem),
+		dbFileName:  dbFileName,
+		store:       store,
+		lockTimeout: DefaultLockTimeout,
 	}
 
 	// We should be all set here, the ToDo struct is ready to go
@@ -66,51 +114,52 @@ func New(dbFile string) (*ToDo, error) {
 	return toDo, nil
 }
 
+// Close releases the storage backend used by the ToDo struct
+func (t *ToDo) Close() error {
+	return t.store.Close()
+}
+
 //------------------------------------------------------------
 // THESE ARE THE PUBLIC FUNCTIONS THAT SUPPORT OUR TODO APP
 //------------------------------------------------------------
 
-// AddItem accepts a ToDoItem and adds it to the DB.
+// AddItem accepts a ToDoItem, gives it a new id and adds it to the DB.
+// Any id already set on the item is ignored.
 // Preconditions:   (1) The database file must exist and be a valid
 //
-//					(2) The item must not already exist in the DB
-//	    				because we use the item.Id as the key, this
-//						function must check if the item already
-//	    				exists in the DB, if so, return an error
+//					(2) The parent and blockers of the item must exist,
+//	    				and if the item is added as done they must be
+//						done too
 //
 // Postconditions:
 //
-//	 (1) The item will be added to the DB
-//		(2) The DB file will be saved with the item added
-//		(3) If there is an error, it will be returned
-func (t *ToDo) AddItem(item ToDoItem) error {
-	//TODO: Implement this function
-	//Start by loading the database into the private map in our struct
-	//see the loadDB() helper.  Then make sure the item we want to load
-	//has a unique ID.  Do this by checking if the item already exists
-	//in the map.  If it does, return an error with a proper message
-	//see (errors.New("MESSAGE GOES HERE")).  If the item does not exist
-	//in the map, add it to the map.  Then save the DB using the saveDB()
-	//helper.  If there are any errors, return them, as appropriate.
-	//If everything there are no errors, this function should return nil
-	//at the end to indicate that the item was properly added to the
-	//database.
-	err := t.loadDB()
-	if err != nil {
-		return err
-	}
+//	 (1) The item will be added to the DB under a new id, one higher
+//		    than any id handed out before, so ids of deleted items are
+//		    never reused, and with its created timestamp set
+//		(2) The DB file will be saved with the item added and the id
+//		    sequence bumped
+//		(3) The new id is returned, if there is an error it will be
+//		    returned instead
+func (t *ToDo) AddItem(item ToDoItem) (int, error) {
+	return t.AddItemContext(context.Background(), item)
+}
 
-	if _, ok := t.toDoMap[item.Id]; ok {
-		return errors.New("trying to add an item with id already existing in database")
-	} else {
-		t.toDoMap[item.Id] = item
-		err := t.saveDB()
-		if err != nil {
-			return err
-		}
+// AddItemContext is AddItem with a context.  If ctx is done while we
+// wait for another process to let go of the database, or before the
+// change is saved, the item is not added and ctx.Err() is returned.
+// The other Context functions treat ctx the same way.
+func (t *ToDo) AddItemContext(ctx context.Context, item ToDoItem) (int, error) {
+	var id int
+	err := t.batch(ctx, OpAdd, func(b *Batch) error {
+		var err error
+		id, err = b.Add(item)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return id, nil
 }
 
 // DeleteItem accepts an item id and removes it from the DB.
@@ -123,7 +172,8 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //
 // Postconditions:
 //
-//	 (1) The item will be removed from the DB
+//	 (1) The item will be removed from the DB, its subtasks become top
+//		    level items and it no longer blocks anything
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
 func (t *ToDo) DeleteItem(id int) error {
@@ -138,21 +188,14 @@ func (t *ToDo) DeleteItem(id int) error {
 	//appropriate.  If everything there are no errors, this function should
 	//return nil at the end to indicate that the item was properly deleted
 	//from the database.
-	err := t.loadDB()
-	if err != nil {
-		return err
-	}
+	return t.DeleteItemContext(context.Background(), id)
+}
 
-	if _, ok := t.toDoMap[id]; ok {
-		delete(t.toDoMap, id)
-		err := t.saveDB()
-		if err != nil {
-			return err
-		}
-		return nil
-	} else {
-		return errors.New("item does not exist in database")
-	}
+// DeleteItemContext is DeleteItem with a context
+func (t *ToDo) DeleteItemContext(ctx context.Context, id int) error {
+	return t.batch(ctx, OpDelete, func(b *Batch) error {
+		return b.Delete(id)
+	})
 }
 
 // UpdateItem accepts a ToDoItem and updates it in the DB.
@@ -165,7 +208,9 @@ func (t *ToDo) DeleteItem(id int) error {
 //
 // Postconditions:
 //
-//	 (1) The item will be updated in the DB
+//	 (1) The item will be updated in the DB, unless the update would
+//		    leave it linked to missing items, create a dependency cycle or
+//		    mark it done while it is blocked (see ChangeItemDoneStatus)
 //		(2) The DB file will be saved with the item updated
 //		(3) If there is an error, it will be returned
 func (t *ToDo) UpdateItem(item ToDoItem) error {
@@ -181,21 +226,32 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	//no errors, this function should return nil at the end to indicate
 	//that the item was properly updated in the database.
 
-	err := t.loadDB()
-	if err != nil {
-		return err
-	}
+	return t.UpdateItemContext(context.Background(), item)
+}
+
+// UpdateItemContext is UpdateItem with a context
+func (t *ToDo) UpdateItemContext(ctx context.Context, item ToDoItem) error {
+	return t.batch(ctx, OpUpdate, func(b *Batch) error {
+		return b.Update(item)
+	})
+}
 
-	if _, ok := t.toDoMap[item.Id]; ok {
-		t.toDoMap[item.Id] = item
-		err := t.saveDB()
+// UpdateItemFuncContext updates an item by handing it, as it is in the
+// database, to fn to change.  The item is read and written under the
+// same lock, so no change made in between is lost.  If fn returns an
+// error nothing is saved and the error is returned.
+func (t *ToDo) UpdateItemFuncContext(ctx context.Context, id int, fn func(item *ToDoItem) error) error {
+	return t.batch(ctx, OpUpdate, func(b *Batch) error {
+		item, err := b.Get(id)
 		if err != nil {
 			return err
 		}
-		return nil
-	} else {
-		return errors.New("item not found in database --- cannot update")
-	}
+		if err := fn(&item); err != nil {
+			return err
+		}
+		item.Id = id
+		return b.Update(item)
+	})
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -225,6 +281,24 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	//as the error value the end to indicate that the item was
 	//properly returned from the database.
 
+	return t.GetItemContext(context.Background(), id)
+}
+
+// GetItemContext is GetItem with a context
+func (t *ToDo) GetItemContext(ctx context.Context, id int) (ToDoItem, error) {
+	if err := ctx.Err(); err != nil {
+		return ToDoItem{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.getItem(id)
+}
+
+// getItem does the work for GetItem.  It takes no lock so it can be
+// used by functions that already hold the database lock.
+func (t *ToDo) getItem(id int) (ToDoItem, error) {
 	err := t.loadDB()
 	if err != nil {
 		return ToDoItem{}, err
@@ -233,7 +307,7 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	if item, ok := t.toDoMap[id]; ok {
 		return item, nil
 	} else {
-		return ToDoItem{}, errors.New("item does not exist in database")
+		return ToDoItem{}, ErrNotFound
 	}
 }
 
@@ -243,7 +317,7 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 //
 // Postconditions:
 //
-//	 (1) All items will be returned, if any exist
+//	 (1) All items will be returned sorted by id, if any exist
 //		(2) If there is an error, it will be returned
 //			along with an empty slice
 //		(3) The database file will not be modified
@@ -259,6 +333,18 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 	//Finally, if there were no errors along the way, return the slice
 	//and nil as the error value.
 
+	return t.GetAllItemsContext(context.Background())
+}
+
+// GetAllItemsContext is GetAllItems with a context
+func (t *ToDo) GetAllItemsContext(ctx context.Context) ([]ToDoItem, error) {
+	if err := ctx.Err(); err != nil {
+		return []ToDoItem{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	err := t.loadDB()
 	if err != nil {
 		return []ToDoItem{}, err
@@ -272,12 +358,20 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 		i++
 	}
 
+	//Map order is random, hand the items back in id order so callers
+	//get the same listing every time
+	sort.Slice(toDoItems, func(i, j int) bool {
+		return toDoItems[i].Id < toDoItems[j].Id
+	})
+
 	return toDoItems, nil
 }
 
 // PrintItem accepts a ToDoItem and prints it to the console
 // in a JSON pretty format. As some help, look at the
 // json.MarshalIndent() function from our in class go tutorial.
+// The todo command line formats items with the render package instead,
+// which supports other output formats as well.
 func (t *ToDo) PrintItem(item ToDoItem) {
 	jsonBytes, _ := json.MarshalIndent(item, "", "  ")
 	fmt.Println(string(jsonBytes))
@@ -320,113 +414,172 @@ func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 //
 // Postconditions:
 //
-//	 (1) The items status in the database will be updated
+//	 (1) The items status in the database will be updated.  An item
+//		    is not marked done while any of its blockers or subtasks are
+//		    still open, a *BlockedError is returned instead (see
+//		    ForceItemDone).  Completing a recurring item adds a new item
+//		    for its next occurrence.
 //		(2) If there is an error, it will be returned.
-//		(3) This function MUST use existing functionality for most of its
-//			work.  For example, it should call GetItem() to get the item
-//			from the DB, then it should call UpdateItem() to update the
-//			item in the DB (after the status is changed).
 func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
-	//TODO: Implement this function for EXTRA CREDIT if you want
-	//This function builds on all of the other functions you have
-	//implemented.  It should call GetItem() to get the item from
-	//the DB, then it should call UpdateItem() to update the item
-	//in the DB (after the status is changed).  If there are any
-	//errors along the way, return them.  If everything is successful
-	//return nil at the end to indicate that the item was properly
+	return t.changeItemDoneStatus(context.Background(), id, value, false)
+}
 
-	err := t.loadDB()
-	if err != nil {
-		return err
-	}
+// ChangeItemDoneStatusContext is ChangeItemDoneStatus with a context
+func (t *ToDo) ChangeItemDoneStatusContext(ctx context.Context, id int, value bool) error {
+	return t.changeItemDoneStatus(ctx, id, value, false)
+}
 
-	item, err := t.GetItem(id)
-	if err != nil {
-		return err
-	}
+// ForceItemDone marks an item done even if items it depends on are
+// still open
+func (t *ToDo) ForceItemDone(id int) error {
+	return t.changeItemDoneStatus(context.Background(), id, true, true)
+}
 
-	item.IsDone = value
-	err = t.UpdateItem(item)
-	if err != nil {
-		return err
+// ForceItemDoneContext is ForceItemDone with a context
+func (t *ToDo) ForceItemDoneContext(ctx context.Context, id int) error {
+	return t.changeItemDoneStatus(ctx, id, true, true)
+}
+
+// ToggleItemDoneContext flips the done status of an item
+func (t *ToDo) ToggleItemDoneContext(ctx context.Context, id int) error {
+	return t.batch(ctx, OpDone, func(b *Batch) error {
+		item, err := b.Get(id)
+		if err != nil {
+			return err
+		}
+		if item.IsDone {
+			b.op = OpUndone
+		}
+		return b.SetDone(id, !item.IsDone)
+	})
+}
+
+func (t *ToDo) changeItemDoneStatus(ctx context.Context, id int, value bool, force bool) error {
+	op := OpUndone
+	if value {
+		op = OpDone
 	}
 
-	return nil
+	//The batch holds the lock across both the get and the update, so
+	//nobody can change the item in between
+	return t.batch(ctx, op, func(b *Batch) error {
+		if value && force {
+			return b.ForceDone(id)
+		}
+		return b.SetDone(id, value)
+	})
 }
 
 //------------------------------------------------------------
 // THESE ARE HELPER FUNCTIONS THAT ARE NOT EXPORTED AKA PRIVATE
 //------------------------------------------------------------
 
-// initDB is a helper function that creates a new file with an
-// empty json array.  This is used to make sure that the DB
-// file exists for operations on our ToDo struct.  This function
-// should be called by the New() function if the DB file doesn't
-// exist.  Notice this function does not have a receiver as its
-// used by New() to create the DB file
-func initDB(dbFileName string) error {
-	f, err := os.Create(dbFileName)
-	if err != nil {
+// saveDB hands the items that changed, along with the database wide
+// values in meta, over to the storage backend.
+// Only the changes are passed along so backends that can update
+// records in place don't need to rewrite the whole database.
+func (t *ToDo) saveDB(meta Meta, puts []ToDoItem, deletes []int) error {
+	if err := t.store.Save(meta, puts, deletes); err != nil {
+		//Our map already has the change applied but the file does not,
+		//forget the stamp so the next load starts over from the file
+		t.stamp = nil
 		return err
 	}
 
-	// Given we are working with a json array as our DB structure
-	// we should initialize the file with an empty array, which
-	// in json is represented as "[]
-	_, err = f.Write([]byte("[]"))
-	if err != nil {
-		return err
+	//We hold the database lock, so the file now holds exactly what is in
+	//our map.  Remember its stamp and revision so we don't read back our
+	//own write.
+	t.meta = meta
+	if store, ok := t.store.(revStore); ok {
+		rev, err := store.rev()
+		if err != nil {
+			t.stamp = nil
+			return nil
+		}
+		t.meta.Rev = rev
 	}
 
-	f.Close()
+	stamp, err := statDB(t.dbFileName)
+	if err != nil {
+		t.stamp = nil
+		return nil
+	}
+	t.stamp = stamp
 
 	return nil
 }
 
-func (t *ToDo) saveDB() error {
-	//1. Convert our map into a slice
-	//2. Marshal the slice into json
-	//3. Write the json to our file
-
-	//1. Convert our map into a slice
-	var toDoList []ToDoItem
-	for _, item := range t.toDoMap {
-		toDoList = append(toDoList, item)
+// loadDB makes sure toDoMap matches the database file.  If the file has
+// not changed since we last loaded it (see current), the map is already up to date and
+// the file is not read again.  Otherwise the map is replaced with a fresh
+// copy, so items deleted by another process don't linger around.
+func (t *ToDo) loadDB() error {
+	//Stat before loading, if the file changes while we read it we end up
+	//with an older stamp than the contents and simply load again next time
+	stamp, err := statDB(t.dbFileName)
+	if err != nil {
+		return err
 	}
 
-	//2. Marshal the slice into json, lets pretty print it, but
-	//   this is not required
-	data, err := json.MarshalIndent(toDoList, "", "  ")
-	if err != nil {
+	if current, err := t.current(stamp); err != nil || current {
 		return err
 	}
 
-	//3. Write the json to our file
-	err = os.WriteFile(t.dbFileName, data, 0644)
+	toDoMap, meta, err := t.store.Load()
 	if err != nil {
 		return err
 	}
 
+	t.toDoMap = toDoMap
+	t.meta = meta
+	t.stamp = stamp
+
 	return nil
 }
 
-func (t *ToDo) loadDB() error {
-	data, err := os.ReadFile(t.dbFileName)
-	if err != nil {
-		return err
+// nextId returns the id for a new item.  Normally that is the next
+// number in the sequence, but databases written before we kept a
+// sequence start from the highest id they hold.
+func (t *ToDo) nextId() int {
+	return nextId(t.meta.Seq, t.toDoMap)
+}
+
+func nextId(seq int, items DbMap) int {
+	id := seq
+	for itemId := range items {
+		if itemId > id {
+			id = itemId
+		}
 	}
 
-	//Now let's unmarshal the data into our map
-	var toDoList []ToDoItem
-	err = json.Unmarshal(data, &toDoList)
-	if err != nil {
-		return err
+	return id + 1
+}
+
+// stampItem sets the timestamps on an item that is about to be saved.
+// old is the item as it is currently stored, or nil for a new item.
+func stampItem(item *ToDoItem, old *ToDoItem) {
+	now := time.Now().Truncate(time.Second)
+
+	item.UpdatedAt = now
+	if old == nil {
+		item.CreatedAt = now
+	} else {
+		item.CreatedAt = old.CreatedAt
 	}
 
-	//Now let's iterate over our slice and add each item to our map
-	for _, item := range toDoList {
-		t.toDoMap[item.Id] = item
+	switch {
+	case !item.IsDone:
+		item.CompletedAt = nil
+	case old != nil && old.IsDone && old.CompletedAt != nil:
+		item.CompletedAt = old.CompletedAt
+	default:
+		item.CompletedAt = &now
 	}
 
-	return nil
+	//Only Archive and Restore move items in and out of the archive, and
+	//an item that is no longer done comes back out of it
+	item.ArchivedAt = nil
+	if old != nil && item.IsDone {
+		item.ArchivedAt = old.ArchivedAt
+	}
 }
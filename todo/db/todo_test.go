@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB opens a fresh JSON database in a temporary directory, it is
+// closed when the test ends
+func openTestDB(t *testing.T) *ToDo {
+	t.Helper()
+	return openDB(t, filepath.Join(t.TempDir(), "todo.json"))
+}
+
+// openDB opens the database at dbFile, it is closed when the test ends
+func openDB(t *testing.T, dbFile string) *ToDo {
+	t.Helper()
+
+	todo, err := New(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { todo.Close() })
+	return todo
+}
+
+// addItems adds an item for every title and returns their ids
+func addItems(t *testing.T, todo *ToDo, titles ...string) []int {
+	t.Helper()
+
+	ids := make([]int, len(titles))
+	for i, title := range titles {
+		id, err := todo.AddItem(ToDoItem{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// The REST routes read and change an item in one go, they are journaled
+// like the same change made from the command line
+func TestUpdateItemFuncAndToggle(t *testing.T) {
+	todo := openTestDB(t)
+	ids := addItems(t, todo, "a")
+	ctx := context.Background()
+
+	err := todo.UpdateItemFuncContext(ctx, ids[0], func(item *ToDoItem) error {
+		item.Title = "renamed"
+		item.Id = 99
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	err = todo.UpdateItemFuncContext(ctx, ids[0], func(item *ToDoItem) error {
+		item.Title = "lost"
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("got %v, want the error of fn", err)
+	}
+	if item, err := todo.GetItem(ids[0]); err != nil || item.Title != "renamed" {
+		t.Errorf("got %q, %v, want the first change only", item.Title, err)
+	}
+
+	for _, want := range []bool{true, false} {
+		if err := todo.ToggleItemDoneContext(ctx, ids[0]); err != nil {
+			t.Fatal(err)
+		}
+		if item, err := todo.GetItem(ids[0]); err != nil || item.IsDone != want {
+			t.Errorf("got done %v, %v, want %v", item.IsDone, err, want)
+		}
+	}
+	if err := todo.ToggleItemDoneContext(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v toggling a missing item, want ErrNotFound", err)
+	}
+
+	history, err := todo.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []JournalOp
+	for _, entry := range history {
+		ops = append(ops, entry.Op)
+	}
+	want := []JournalOp{OpUndone, OpDone, OpUpdate, OpAdd}
+	if len(ops) != len(want) {
+		t.Fatalf("got journal %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Fatalf("got journal %v, want %v", ops, want)
+		}
+	}
+}
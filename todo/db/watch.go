@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is a reasonable polling interval for Watch
+const DefaultWatchInterval = time.Second
+
+// fileStamp records enough about the database file to tell whether it
+// changed since we last loaded it.
+type fileStamp struct {
+	info os.FileInfo
+}
+
+func statDB(dbFileName string) (*fileStamp, error) {
+	info, err := os.Stat(dbFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStamp{info: info}, nil
+}
+
+// equal reports whether two stamps describe the same version of the
+// file.  The JSON backend replaces the file on every save, so checking
+// it is still the same file catches changes even when the modification
+// time is too coarse to notice them.
+func (s *fileStamp) equal(other *fileStamp) bool {
+	return os.SameFile(s.info, other.info) &&
+		s.info.ModTime().Equal(other.info.ModTime()) &&
+		s.info.Size() == other.info.Size()
+}
+
+// current reports whether the map still matches the database, given a
+// fresh stamp of the file.  The bolt backend writes its file in place,
+// and a write that keeps the size within the same modification time
+// leaves the stamp as it was, so a store that keeps a revision has to
+// still be at the one we loaded as well.
+func (t *ToDo) current(stamp *fileStamp) (bool, error) {
+	if t.stamp == nil || !t.stamp.equal(stamp) {
+		return false, nil
+	}
+
+	store, ok := t.store.(revStore)
+	if !ok {
+		return true, nil
+	}
+	rev, err := store.rev()
+	if err != nil {
+		return false, err
+	}
+	return rev == t.meta.Rev, nil
+}
+
+// Reload throws away the in memory copy of the database and reads it
+// again from the file, whether or not the file looks like it changed.
+//
+// Normally this is not needed, every public function already reloads
+// the database if another process changed the file.
+func (t *ToDo) Reload() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stamp = nil
+	return t.loadDB()
+}
+
+// Watch is for programs that keep a ToDo around for a long time and want
+// to know when another process changes the database.  It checks the file
+// every interval and, when it changed, reloads it and sends on the
+// returned channel.  A nil value means the new contents were loaded, an
+// error means the file changed but could not be loaded.
+//
+// Notifications are not queued up, if the receiver falls behind several
+// changes are reported as one.  Watching stops and the channel is closed
+// when ctx is done.
+func (t *ToDo) Watch(ctx context.Context, interval time.Duration) <-chan error {
+	changes := make(chan error, 1)
+
+	go func() {
+		defer close(changes)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			changed, err := t.refresh()
+			if !changed {
+				continue
+			}
+
+			select {
+			case changes <- err:
+			default:
+			}
+		}
+	}()
+
+	return changes
+}
+
+// refresh reloads the database if the file changed since the last load.
+// It reports whether there was a change.
+func (t *ToDo) refresh() (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	//Nothing loaded yet, so there is nothing to compare against
+	if t.stamp == nil {
+		err := t.loadDB()
+		return err != nil, err
+	}
+
+	stamp, err := statDB(t.dbFileName)
+	if err != nil {
+		return true, err
+	}
+	if current, err := t.current(stamp); err != nil || current {
+		return err != nil, err
+	}
+
+	return true, t.loadDB()
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A bolt write that keeps the size of the file and its modification
+// time has to be noticed all the same
+func TestLoadNoticesInPlaceWrite(t *testing.T) {
+	dbFile := "bolt://" + filepath.Join(t.TempDir(), "todo.db")
+
+	a, err := New(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	id, err := a.AddItem(ToDoItem{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.GetItem(id); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(a.dbFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if err := b.UpdateItem(ToDoItem{Id: id, Title: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(a.dbFileName, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := os.Stat(a.dbFileName); err != nil || after.Size() != info.Size() {
+		t.Skip("the write changed the size of the file, nothing to test")
+	}
+
+	item, err := a.GetItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Title != "other" {
+		t.Errorf("got title %q, want the one written by the other process", item.Title)
+	}
+}
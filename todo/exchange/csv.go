@@ -0,0 +1,238 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// csvColumns is the header written by exportCSV.  On import the columns
+// may come in any order and only title is required.
+var csvColumns = []string{"id", "title", "list", "done", "due", "priority", "tags", "notes", "parent", "blockedBy", "repeat", "created", "updated", "completed"}
+
+// exportCSV writes one row per item after a header row.  Tags are joined
+// with semicolons and times are written in RFC 3339 form.
+func exportCSV(w io.Writer, items []db.ToDoItem) error {
+	out := csv.NewWriter(w)
+
+	if err := out.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		row := []string{
+			strconv.Itoa(item.Id),
+			item.Title,
+			item.List,
+			strconv.FormatBool(item.IsDone),
+			formatTime(item.Due),
+			"",
+			strings.Join(item.Tags, ";"),
+			item.Notes,
+			"",
+			joinIds(item.BlockedBy, ";"),
+			"",
+			formatTime(&item.CreatedAt),
+			formatTime(&item.UpdatedAt),
+			formatTime(item.CompletedAt),
+		}
+		if item.Priority != db.PriorityNone {
+			row[5] = item.Priority.String()
+		}
+		if item.ParentId != 0 {
+			row[8] = strconv.Itoa(item.ParentId)
+		}
+		if item.Recur != nil {
+			row[10] = item.Recur.String()
+		}
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// importCSV reads rows of items, using the header row to find the columns
+func importCSV(data []byte) ([]db.ToDoItem, error) {
+	in := csv.NewReader(strings.NewReader(string(data)))
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+
+	header, err := in.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv file needs a header row with at least a title column")
+	}
+
+	var items []db.ToDoItem
+	for {
+		row, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := in.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		item, err := csvItem(field)
+		if err != nil {
+			return nil, lineError(line, err)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// csvItem builds an item from the fields of one row
+func csvItem(field func(name string) string) (db.ToDoItem, error) {
+	var item db.ToDoItem
+	var err error
+
+	item.Title = field("title")
+	if item.Title == "" {
+		return item, errors.New("item has no title")
+	}
+
+	item.List = field("list")
+
+	if id := field("id"); id != "" {
+		if item.Id, err = strconv.Atoi(id); err != nil {
+			return item, fmt.Errorf("invalid item id %q", id)
+		}
+	}
+
+	if done := field("done"); done != "" {
+		if item.IsDone, err = parseDone(done); err != nil {
+			return item, err
+		}
+	}
+
+	if item.Due, err = db.ParseDate(field("due")); err != nil {
+		return item, err
+	}
+
+	if priority := field("priority"); priority != "" {
+		if item.Priority, err = db.ParsePriority(priority); err != nil {
+			return item, err
+		}
+	}
+
+	item.Tags = splitTags(field("tags"))
+	item.Notes = field("notes")
+
+	if parent := field("parent"); parent != "" {
+		if item.ParentId, err = strconv.Atoi(parent); err != nil {
+			return item, fmt.Errorf("invalid parent id %q", parent)
+		}
+	}
+	if item.BlockedBy, err = splitIds(field("blockedby")); err != nil {
+		return item, err
+	}
+	if item.Recur, err = db.ParseRecurrence(field("repeat")); err != nil {
+		return item, err
+	}
+
+	if created, err := db.ParseDate(field("created")); err != nil {
+		return item, err
+	} else if created != nil {
+		item.CreatedAt = *created
+	}
+	if updated, err := db.ParseDate(field("updated")); err != nil {
+		return item, err
+	} else if updated != nil {
+		item.UpdatedAt = *updated
+	}
+	if item.CompletedAt, err = db.ParseDate(field("completed")); err != nil {
+		return item, err
+	}
+
+	return item, nil
+}
+
+// parseDone accepts the usual ways a spreadsheet says yes or no
+func parseDone(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "x", "y", "yes", "done":
+		return true, nil
+	case "n", "no", "pending":
+		return false, nil
+	}
+
+	done, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid done status %q, use true or false", s)
+	}
+
+	return done, nil
+}
+
+// splitTags splits a list of tags separated by semicolons or commas
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// splitIds reads a list of item ids separated by semicolons or commas
+func splitIds(s string) ([]int, error) {
+	var ids []int
+	for _, field := range splitTags(s) {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item id %q", field)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func joinIds(ids []int, sep string) string {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(list, sep)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
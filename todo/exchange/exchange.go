@@ -0,0 +1,152 @@
+// Package exchange reads and writes todo items in formats other tools
+// understand, so a list can be moved in and out of the todo database.
+//
+// Every format keeps the title and done status of an item.  How much of
+// the rest survives a round trip depends on the format:
+//
+//	json     everything, it is the same item layout the database uses
+//	csv      everything
+//	md       a Markdown checklist, keeps everything except the timestamps
+//	todotxt  the todo.txt format, keeps everything except the notes and
+//	         the updated time, and the created and completed days only
+package exchange
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+// Format names one of the file formats items can be exchanged in
+type Format string
+
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	Markdown Format = "md"
+	TodoTxt  Format = "todotxt"
+)
+
+// Formats lists every supported Format
+var Formats = []Format{JSON, CSV, Markdown, TodoTxt}
+
+// formatAliases are other names accepted by ParseFormat
+var formatAliases = map[string]Format{
+	"markdown": Markdown,
+	"todo.txt": TodoTxt,
+	"txt":      TodoTxt,
+}
+
+// ParseFormat checks that s names a supported format
+func ParseFormat(s string) (Format, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for _, format := range Formats {
+		if s == string(format) {
+			return format, nil
+		}
+	}
+	if format, ok := formatAliases[s]; ok {
+		return format, nil
+	}
+
+	return "", fmt.Errorf("invalid format %q, use one of %v", s, Formats)
+}
+
+// FormatForFile guesses the format of a file from its extension.  ok is
+// false when the extension does not give it away.
+func FormatForFile(fileName string) (format Format, ok bool) {
+	if strings.EqualFold(filepath.Base(fileName), "todo.txt") {
+		return TodoTxt, true
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return JSON, true
+	case ".csv":
+		return CSV, true
+	case ".md", ".markdown":
+		return Markdown, true
+	case ".txt":
+		return TodoTxt, true
+	}
+
+	return "", false
+}
+
+// checkboxLine matches a Markdown checklist item, "- [ ] title" or
+// "- [x] title"
+var checkboxLine = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s?(.*)$`)
+
+// DetectFormat works out the format of data read from fileName.  The
+// extension is trusted if it is a known one, otherwise the content is
+// sniffed.  Anything that does not look like one of the other formats is
+// taken to be todo.txt, which is just lines of text.
+func DetectFormat(fileName string, data []byte) Format {
+	if format, ok := FormatForFile(fileName); ok {
+		return format
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return JSON
+	}
+
+	lines := strings.Split(string(trimmed), "\n")
+	for _, line := range lines {
+		if checkboxLine.MatchString(line) {
+			return Markdown
+		}
+	}
+
+	header := strings.ToLower(lines[0])
+	if strings.Contains(header, ",") && strings.Contains(header, "title") {
+		return CSV
+	}
+
+	return TodoTxt
+}
+
+// Export writes items to w in the given format
+func Export(w io.Writer, format Format, items []db.ToDoItem) error {
+	switch format {
+	case JSON:
+		return exportJSON(w, items)
+	case CSV:
+		return exportCSV(w, items)
+	case Markdown:
+		return exportMarkdown(w, items)
+	case TodoTxt:
+		return exportTodoTxt(w, items)
+	default:
+		return fmt.Errorf("invalid format %q, use one of %v", format, Formats)
+	}
+}
+
+// Import reads items in the given format.  Items the file does not give
+// an id come back with an id of 0, see db.ToDo.ImportItems for how those
+// are numbered.
+func Import(data []byte, format Format) ([]db.ToDoItem, error) {
+	switch format {
+	case JSON:
+		return importJSON(data)
+	case CSV:
+		return importCSV(data)
+	case Markdown:
+		return importMarkdown(data)
+	case TodoTxt:
+		return importTodoTxt(data)
+	default:
+		return nil, fmt.Errorf("invalid format %q, use one of %v", format, Formats)
+	}
+}
+
+// lineError reports a problem on a given line of the file being imported
+func lineError(line int, err error) error {
+	return fmt.Errorf("line %d: %w", line, err)
+}
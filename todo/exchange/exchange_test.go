@@ -0,0 +1,124 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// testItems uses every field some format keeps
+func testItems() []db.ToDoItem {
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	updated := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	completed := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	dueAt := time.Date(2024, 6, 2, 14, 30, 0, 0, time.Local)
+
+	return []db.ToDoItem{
+		{
+			Id: 1, Title: "Plan the trip", List: "home", Due: &due,
+			Recur: &db.Recurrence{Every: 2, Unit: db.RecurWeek}, Priority: db.PriorityHigh,
+			Tags: []string{"travel", "summer"}, Notes: "Book the train\nand the hotel",
+			CreatedAt: created, UpdatedAt: updated,
+		},
+		{
+			Id: 2, Title: "Book the hotel", List: "home", IsDone: true, Due: &dueAt,
+			ParentId: 1, CreatedAt: created, UpdatedAt: updated, CompletedAt: &completed,
+		},
+		{
+			Id: 3, Title: "Pack", List: "home", BlockedBy: []int{2},
+			Priority: db.PriorityLow, CreatedAt: created, UpdatedAt: updated,
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			want := testItems()
+
+			var buf bytes.Buffer
+			if err := Export(&buf, format, want); err != nil {
+				t.Fatal(err)
+			}
+			if got := DetectFormat("items", buf.Bytes()); got != format {
+				t.Errorf("exported %s was detected as %s", format, got)
+			}
+
+			got, err := Import(buf.Bytes(), format)
+			if err != nil {
+				t.Fatalf("%v in\n%s", err, buf.String())
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d items, want %d from\n%s", len(got), len(want), buf.String())
+			}
+
+			for i := range want {
+				//What the format does not keep, see the package comment
+				switch format {
+				case Markdown:
+					got[i].CreatedAt, got[i].UpdatedAt, got[i].CompletedAt = want[i].CreatedAt, want[i].UpdatedAt, want[i].CompletedAt
+				case TodoTxt:
+					//Created and completed are only kept to the day
+					if day := "2006-01-02"; got[i].CreatedAt.Format(day) == want[i].CreatedAt.Format(day) {
+						got[i].CreatedAt = want[i].CreatedAt
+					}
+					got[i].Notes = want[i].Notes
+					got[i].UpdatedAt = want[i].UpdatedAt
+				}
+				if !sameItem(got[i], want[i]) {
+					t.Errorf("item %d:\n got %+v\nwant %+v\nfrom\n%s", want[i].Id, got[i], want[i], buf.String())
+				}
+			}
+		})
+	}
+}
+
+// sameItem compares items, times by the instant they stand for
+func sameItem(a, b db.ToDoItem) bool {
+	sameTime := func(x, y *time.Time) bool {
+		return (x == nil) == (y == nil) && (x == nil || x.Equal(*y))
+	}
+	if !sameTime(a.Due, b.Due) || !sameTime(a.CompletedAt, b.CompletedAt) || !sameTime(a.ArchivedAt, b.ArchivedAt) ||
+		!a.CreatedAt.Equal(b.CreatedAt) || !a.UpdatedAt.Equal(b.UpdatedAt) {
+		return false
+	}
+	a.Due, a.CompletedAt, a.ArchivedAt = b.Due, b.CompletedAt, b.ArchivedAt
+	a.CreatedAt, a.UpdatedAt = b.CreatedAt, b.UpdatedAt
+	return reflect.DeepEqual(a, b)
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		fileName string
+		data     string
+		want     Format
+	}{
+		{"todo.txt", "- [ ] looks like markdown", TodoTxt},
+		{"items.CSV", "", CSV},
+		{"notes.markdown", "", Markdown},
+		{"export", `[{"id": 1}]`, JSON},
+		{"export", "# home\n\n- [x] done", Markdown},
+		{"export", "id,title,done\n1,a,false", CSV},
+		{"export", "(A) Call mom +family", TodoTxt},
+	}
+	for _, test := range tests {
+		if got := DetectFormat(test.fileName, []byte(test.data)); got != test.want {
+			t.Errorf("DetectFormat(%q, %q) = %s, want %s", test.fileName, test.data, got, test.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"JSON": JSON, " csv ": CSV, "markdown": Markdown, "todo.txt": TodoTxt} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat accepted xml")
+	}
+}
@@ -0,0 +1,46 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"drexel.edu/todo/db"
+)
+
+// exportJSON writes the items as a JSON array
+func exportJSON(w io.Writer, items []db.ToDoItem) error {
+	if items == nil {
+		items = []db.ToDoItem{}
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// importJSON reads a JSON array of items.  A todo database file (an
+// object with an "items" array) can be imported directly as well.
+func importJSON(data []byte) ([]db.ToDoItem, error) {
+	var items []db.ToDoItem
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	var file struct {
+		Items []db.ToDoItem `json:"items"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	return file.Items, nil
+}
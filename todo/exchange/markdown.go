@@ -0,0 +1,129 @@
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/render"
+)
+
+// The Markdown format is a GitHub style checklist with a heading for each
+// list.  Details follow the title as #tags and key:value words, like in
+// todo.txt, and the notes are indented on the lines below the item:
+//
+//	## home
+//
+//	- [ ] Buy milk #shopping due:2023-10-05 priority:high id:3
+//	  Semi-skimmed, two litres
+//	- [x] Learn Go / GoLang id:1
+//
+// On import a heading puts the items below it on the list it names (with
+// any spaces turned into dashes), and anything else that is not a
+// checklist item or the notes under one is ignored.
+
+var headingLine = regexp.MustCompile(`^#{1,6}\s+(.*\S)\s*$`)
+
+func exportMarkdown(w io.Writer, items []db.ToDoItem) error {
+	out := bufio.NewWriter(w)
+
+	//Group the items by list, keeping their order within each list
+	byList := make(map[string][]db.ToDoItem)
+	var lists []string
+	for _, item := range items {
+		if _, ok := byList[item.List]; !ok {
+			lists = append(lists, item.List)
+		}
+		byList[item.List] = append(byList[item.List], item)
+	}
+	sort.Strings(lists)
+
+	for i, list := range lists {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if list != "" {
+			fmt.Fprintf(out, "## %s\n\n", list)
+		}
+
+		for _, item := range byList[list] {
+			box := " "
+			if item.IsDone {
+				box = "x"
+			}
+
+			words := append([]string{render.OneLine(item.Title)}, metadataWords(item, "#")...)
+			if item.Priority != db.PriorityNone {
+				words = append(words, "priority:"+item.Priority.String())
+			}
+			fmt.Fprintf(out, "- [%s] %s\n", box, strings.Join(words, " "))
+
+			if item.Notes != "" {
+				for _, line := range strings.Split(item.Notes, "\n") {
+					fmt.Fprintln(out, "  "+line)
+				}
+			}
+		}
+	}
+
+	return out.Flush()
+}
+
+func importMarkdown(data []byte) ([]db.ToDoItem, error) {
+	var items []db.ToDoItem
+	var notes []string
+	inItem := false
+	list := ""
+
+	//Notes are only known once we reach the next item, so they are added
+	//to the previous item as we go
+	flushNotes := func() {
+		if len(items) > 0 && len(notes) > 0 {
+			items[len(items)-1].Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+		}
+		notes = nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if m := checkboxLine.FindStringSubmatch(line); m != nil {
+			flushNotes()
+
+			item := db.ToDoItem{IsDone: m[1] != " ", List: list}
+			if err := parseMetadataWords(&item, strings.Fields(m[2]), "#"); err != nil {
+				return nil, lineError(i+1, err)
+			}
+			if item.Title == "" {
+				return nil, lineError(i+1, fmt.Errorf("item has no title"))
+			}
+
+			items = append(items, item)
+			inItem = true
+			continue
+		}
+
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			flushNotes()
+			inItem = false
+			list = strings.Join(strings.Fields(m[1]), "-")
+			continue
+		}
+
+		//Indented lines right under an item are its notes, anything else
+		//ends the item
+		if inItem && (strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")) {
+			notes = append(notes, strings.TrimSpace(line))
+			continue
+		}
+		flushNotes()
+		inItem = false
+	}
+	flushNotes()
+
+	return items, nil
+}
@@ -0,0 +1,249 @@
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/render"
+)
+
+// The todo.txt format (https://github.com/todotxt/todo.txt) is one item
+// per line:
+//
+//	x 2023-10-02 2023-10-01 Buy milk +shopping due:2023-10-05 id:3
+//	(A) 2023-10-01 Call the bank +home
+//
+// A leading "x" marks the item done and is followed by the completion
+// date, "(A)" to "(C)" give the priority of pending items, then comes the
+// creation date.  Tags are +project words (@context words are imported as
+// tags too) and anything else is stored as key:value words.  We use
+// due:, list: (left out for the default list), id:, parent:, blocked-by:
+// (a comma separated list of ids), rec: (the repeat rule, as in rec:2w)
+// with until:, and pri: (the priority of done items, which lose their
+// "(A)").
+
+const todoTxtDate = "2006-01-02"
+
+var (
+	todoTxtPriority = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtDateWord = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// priorityLetters maps our priorities to todo.txt ones
+var priorityLetters = map[db.Priority]string{
+	db.PriorityHigh:   "A",
+	db.PriorityMedium: "B",
+	db.PriorityLow:    "C",
+}
+
+func priorityFromLetter(letter string) db.Priority {
+	for p, l := range priorityLetters {
+		if l == letter {
+			return p
+		}
+	}
+
+	//todo.txt goes all the way down to Z, anything below C is low
+	return db.PriorityLow
+}
+
+func exportTodoTxt(w io.Writer, items []db.ToDoItem) error {
+	out := bufio.NewWriter(w)
+
+	for _, item := range items {
+		var words []string
+
+		if item.IsDone {
+			words = append(words, "x")
+			if item.CompletedAt != nil {
+				words = append(words, item.CompletedAt.Local().Format(todoTxtDate))
+			}
+		} else if letter, ok := priorityLetters[item.Priority]; ok {
+			words = append(words, "("+letter+")")
+		}
+		if !item.CreatedAt.IsZero() {
+			words = append(words, item.CreatedAt.Local().Format(todoTxtDate))
+		}
+
+		words = append(words, render.OneLine(item.Title))
+		words = append(words, metadataWords(item, "+")...)
+		if item.List != "" && item.List != db.DefaultList {
+			words = append(words, "list:"+item.List)
+		}
+		if letter, ok := priorityLetters[item.Priority]; ok && item.IsDone {
+			words = append(words, "pri:"+letter)
+		}
+
+		if _, err := fmt.Fprintln(out, strings.Join(words, " ")); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+func importTodoTxt(data []byte) ([]db.ToDoItem, error) {
+	var items []db.ToDoItem
+
+	for i, line := range strings.Split(string(data), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		var item db.ToDoItem
+		if words[0] == "x" {
+			item.IsDone = true
+			words = words[1:]
+			//A done item has its completion date first, then the optional
+			//creation date
+			if len(words) > 0 && todoTxtDateWord.MatchString(words[0]) {
+				completed, err := db.ParseDate(words[0])
+				if err != nil {
+					return nil, lineError(i+1, err)
+				}
+				item.CompletedAt = completed
+				words = words[1:]
+			}
+		}
+		if len(words) > 0 {
+			if m := todoTxtPriority.FindStringSubmatch(words[0]); m != nil {
+				item.Priority = priorityFromLetter(m[1])
+				words = words[1:]
+			}
+		}
+		if len(words) > 0 && todoTxtDateWord.MatchString(words[0]) {
+			created, err := db.ParseDate(words[0])
+			if err != nil {
+				return nil, lineError(i+1, err)
+			}
+			item.CreatedAt = *created
+			words = words[1:]
+		}
+
+		if err := parseMetadataWords(&item, words, "+@"); err != nil {
+			return nil, lineError(i+1, err)
+		}
+		if item.Title == "" {
+			return nil, lineError(i+1, fmt.Errorf("item has no title"))
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// metadataWords are the tag and key:value words written after the title
+// of an item, shared by the todo.txt and Markdown formats.  tagPrefix
+// comes before each tag.
+func metadataWords(item db.ToDoItem, tagPrefix string) []string {
+	var words []string
+
+	for _, tag := range item.Tags {
+		words = append(words, tagPrefix+strings.ReplaceAll(tag, " ", "_"))
+	}
+	if item.Due != nil {
+		words = append(words, "due:"+formatDue(*item.Due))
+	}
+	if item.Recur != nil {
+		words = append(words, "rec:"+item.Recur.Short())
+		if item.Recur.Until != nil {
+			words = append(words, "until:"+item.Recur.Until.Format(todoTxtDate))
+		}
+	}
+	if item.Id != 0 {
+		words = append(words, "id:"+strconv.Itoa(item.Id))
+	}
+	if item.ParentId != 0 {
+		words = append(words, "parent:"+strconv.Itoa(item.ParentId))
+	}
+	if len(item.BlockedBy) > 0 {
+		words = append(words, "blocked-by:"+joinIds(item.BlockedBy, ","))
+	}
+
+	return words
+}
+
+// parseMetadataWords splits the words of an item into its title and the
+// tags and key:value words written by metadataWords.  Words starting with
+// one of tagPrefixes are tags.  Unknown keys are left in the title, it is
+// not unusual for a title to contain a colon.
+func parseMetadataWords(item *db.ToDoItem, words []string, tagPrefixes string) error {
+	var title []string
+	var until *time.Time
+
+	for _, word := range words {
+		if len(word) > 1 && strings.ContainsAny(word[:1], tagPrefixes) {
+			item.Tags = append(item.Tags, word[1:])
+			continue
+		}
+
+		key, value, found := strings.Cut(word, ":")
+		if !found || value == "" {
+			title = append(title, word)
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "due":
+			item.Due, err = db.ParseDate(value)
+		case "id":
+			item.Id, err = strconv.Atoi(value)
+			if err != nil {
+				err = fmt.Errorf("invalid item id %q", value)
+			}
+		case "parent":
+			item.ParentId, err = strconv.Atoi(value)
+			if err != nil {
+				err = fmt.Errorf("invalid parent id %q", value)
+			}
+		case "blocked-by":
+			item.BlockedBy, err = splitIds(value)
+		case "list":
+			item.List = value
+		case "rec":
+			item.Recur, err = db.ParseRecurrence(value)
+		case "until":
+			until, err = db.ParseDate(value)
+		case "pri":
+			item.Priority = priorityFromLetter(strings.ToUpper(value))
+		case "priority":
+			item.Priority, err = db.ParsePriority(value)
+		default:
+			title = append(title, word)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	//until: may come before rec:, so it is only applied at the end
+	if until != nil {
+		if item.Recur == nil {
+			return fmt.Errorf("until:%s without a rec: rule", until.Format(todoTxtDate))
+		}
+		item.Recur.Until = until
+	}
+
+	item.Title = strings.Join(title, " ")
+	return nil
+}
+
+// formatDue writes a due date as a single word, leaving the time off
+// when it is midnight
+func formatDue(due time.Time) string {
+	due = due.Local()
+	if due.Hour() == 0 && due.Minute() == 0 {
+		return due.Format(todoTxtDate)
+	}
+
+	return due.Format("2006-01-02T15:04")
+}
@@ -0,0 +1,206 @@
+// Package notify delivers reminders about todo items.  Each way of
+// delivering them is a Notifier, picked by a spec string with Parse, so
+// todo remind can print them, pop them up on the desktop, run a command
+// or post them to a webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// Notifier delivers a reminder
+type Notifier interface {
+	Notify(ctx context.Context, r db.Reminder) error
+}
+
+// Timeout is how long a command or webhook may take to deliver a
+// reminder
+const Timeout = 10 * time.Second
+
+// The notifiers Parse knows about.  A command or URL is given along with
+// the kind, as in webhook=http://localhost:8080/todo.
+const (
+	Stdout  = "stdout"
+	Desktop = "desktop"
+	Command = "command"
+	Webhook = "webhook"
+)
+
+// Kinds lists every kind of notifier, for help texts and error messages
+var Kinds = []string{Stdout, Desktop, Command + "=...", Webhook + "=URL"}
+
+// Payload is the JSON a command reads on its standard input and a webhook
+// is posted
+type Payload struct {
+	Kind    db.ReminderKind `json:"kind"`
+	Message string          `json:"message"`
+	Item    db.ToDoItem     `json:"item"`
+}
+
+func payload(r db.Reminder) Payload {
+	return Payload{Kind: r.Kind, Message: r.Message(), Item: r.Item}
+}
+
+// Parse returns the notifier described by spec
+func Parse(spec string) (Notifier, error) {
+	name, arg, hasArg := strings.Cut(spec, "=")
+	switch strings.ToLower(name) {
+	case Stdout:
+		return Writer(os.Stdout), nil
+	case Desktop:
+		return DesktopNotifier()
+	case Command:
+		if strings.TrimSpace(arg) == "" {
+			return nil, errors.New("command notifier needs a command, as in command=...")
+		}
+		return CommandNotifier(arg), nil
+	case Webhook:
+		if !hasArg {
+			return nil, errors.New("webhook notifier needs a URL, as in webhook=http://localhost:8080/todo")
+		}
+		return WebhookNotifier(arg)
+	default:
+		return nil, fmt.Errorf("invalid notifier %q, use one of %s", spec, strings.Join(Kinds, ", "))
+	}
+}
+
+// writer prints reminders as lines of text
+type writer struct {
+	w io.Writer
+}
+
+// Writer returns a Notifier that prints each reminder as a line of text
+// to w
+func Writer(w io.Writer) Notifier {
+	return writer{w}
+}
+
+func (n writer) Notify(ctx context.Context, r db.Reminder) error {
+	_, err := fmt.Fprintf(n.w, "%s  %s\n", time.Now().Format("2006-01-02 15:04"), r.Message())
+	return err
+}
+
+// command runs a command for each reminder
+type command struct {
+	name string
+	args []string
+	// shell commands get the payload on their standard input and in
+	// their environment
+	shell bool
+}
+
+// CommandNotifier returns a Notifier that runs command with the shell, sh
+// -c on Unix and cmd /C on Windows, for each reminder.  The Payload is
+// written to its standard input as JSON, and TODO_REMINDER (the message),
+// TODO_KIND, TODO_ITEM_ID and TODO_TITLE are set in its environment.
+// What the command prints goes to standard error.
+func CommandNotifier(cmd string) Notifier {
+	if runtime.GOOS == "windows" {
+		return command{name: "cmd", args: []string{"/C", cmd}, shell: true}
+	}
+	return command{name: "sh", args: []string{"-c", cmd}, shell: true}
+}
+
+// DesktopNotifier returns a Notifier that pops reminders up on the
+// desktop, with notify-send on Linux and the BSDs and osascript on macOS
+func DesktopNotifier() (Notifier, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return command{name: "osascript"}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return nil, errors.New("desktop notifier needs notify-send, use command=... to run another tool")
+		}
+		return command{name: "notify-send"}, nil
+	default:
+		return nil, fmt.Errorf("desktop notifications are not supported on %s, use command=... instead", runtime.GOOS)
+	}
+}
+
+func (n command) Notify(ctx context.Context, r db.Reminder) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	args := n.args
+	switch n.name {
+	case "notify-send":
+		args = []string{"--app-name=todo", "todo", r.Message()}
+	case "osascript":
+		args = []string{"-e", fmt.Sprintf("display notification %s with title \"todo\"", strconv.Quote(r.Message()))}
+	}
+
+	cmd := exec.CommandContext(ctx, n.name, args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if n.shell {
+		data, err := json.Marshal(payload(r))
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Env = append(os.Environ(),
+			"TODO_REMINDER="+r.Message(),
+			"TODO_KIND="+string(r.Kind),
+			"TODO_ITEM_ID="+strconv.Itoa(r.Item.Id),
+			"TODO_TITLE="+r.Item.Title)
+	}
+
+	return cmd.Run()
+}
+
+// webhook posts reminders to a URL
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+// WebhookNotifier returns a Notifier that posts each reminder as a JSON
+// Payload to rawURL.  Any status other than 2xx fails the reminder.
+func WebhookNotifier(rawURL string) (Notifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q, use an http or https URL", rawURL)
+	}
+
+	return webhook{url: rawURL, client: &http.Client{Timeout: Timeout}}, nil
+}
+
+func (n webhook) Notify(ctx context.Context, r db.Reminder) error {
+	data, err := json.Marshal(payload(r))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s answered %s", n.url, resp.Status)
+	}
+	return nil
+}
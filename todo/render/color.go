@@ -0,0 +1,39 @@
+package render
+
+import (
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// ANSI escape codes for the few colors we use
+const (
+	colorReset  = "\x1b[0m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+)
+
+// itemColor picks the color an item is shown in: done items are dimmed,
+// overdue items are red and high priority items yellow
+func itemColor(item db.ToDoItem, now time.Time) string {
+	switch {
+	case item.IsDone:
+		return colorDim
+	case item.IsOverdue(now):
+		return colorRed
+	case item.Priority == db.PriorityHigh:
+		return colorYellow
+	default:
+		return ""
+	}
+}
+
+// paint wraps s in color, if there is one
+func paint(s string, color string) string {
+	if color == "" {
+		return s
+	}
+
+	return color + s + colorReset
+}
@@ -0,0 +1,91 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"drexel.edu/todo/db"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonRenderer writes pretty printed JSON, a single object for one item
+// and an array for a listing
+type jsonRenderer struct{}
+
+func (jsonRenderer) RenderItem(w io.Writer, item db.ToDoItem) error {
+	return writeJSON(w, item)
+}
+
+func (jsonRenderer) RenderItems(w io.Writer, items []db.ToDoItem) error {
+	if items == nil {
+		items = []db.ToDoItem{}
+	}
+
+	return writeJSON(w, items)
+}
+
+func (jsonRenderer) RenderDocument(w io.Writer, v any) error {
+	return writeJSON(w, v)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// yamlRenderer writes the same document as jsonRenderer, in YAML
+type yamlRenderer struct{}
+
+func (yamlRenderer) RenderItem(w io.Writer, item db.ToDoItem) error {
+	return writeYAML(w, item)
+}
+
+func (yamlRenderer) RenderItems(w io.Writer, items []db.ToDoItem) error {
+	if items == nil {
+		items = []db.ToDoItem{}
+	}
+
+	return writeYAML(w, items)
+}
+
+func (yamlRenderer) RenderDocument(w io.Writer, v any) error {
+	return writeYAML(w, v)
+}
+
+// writeYAML goes through JSON so the YAML has the same field names and
+// value formats as the JSON output and the database file
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	//JSON is valid YAML, reading it into a node keeps the field order
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// blockStyle drops the flow (JSON) style the nodes were read with, so
+// the encoder writes them out as ordinary block style YAML
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
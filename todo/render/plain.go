@@ -0,0 +1,70 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// plainRenderer writes one line per item, with the details that are set
+// in brackets after the title
+type plainRenderer struct {
+	color bool
+}
+
+func (r plainRenderer) RenderItem(w io.Writer, item db.ToDoItem) error {
+	return r.RenderItems(w, []db.ToDoItem{item})
+}
+
+func (r plainRenderer) RenderItems(w io.Writer, items []db.ToDoItem) error {
+	now := time.Now()
+	for _, item := range items {
+		line := Line(item)
+		if r.color {
+			line = paint(line, itemColor(item, now))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Line describes an item on a single line, for example
+//
+//	[ ] 3  Buy milk  (due 2023-10-05, high, #shopping)
+func Line(item db.ToDoItem) string {
+	line := fmt.Sprintf("%s %d  %s", checkbox(item), item.Id, OneLine(item.Title))
+
+	var details []string
+	if item.Due != nil {
+		details = append(details, "due "+formatDue(*item.Due))
+	}
+	if item.Priority != db.PriorityNone {
+		details = append(details, item.Priority.String())
+	}
+	for _, tag := range item.Tags {
+		details = append(details, "#"+tag)
+	}
+	if item.Recur != nil {
+		details = append(details, "repeats "+item.Recur.String())
+	}
+	if len(item.BlockedBy) > 0 {
+		blockers := make([]string, len(item.BlockedBy))
+		for i, id := range item.BlockedBy {
+			blockers[i] = strconv.Itoa(id)
+		}
+		details = append(details, "blocked by "+strings.Join(blockers, ", "))
+	}
+
+	if len(details) > 0 {
+		line += "  (" + strings.Join(details, ", ") + ")"
+	}
+
+	return line
+}
@@ -0,0 +1,79 @@
+// Package render formats todo items for the command line.  Each output
+// mode is a Renderer, picked by name with New, so humans get aligned,
+// colored tables and scripts get JSON, YAML or whatever a Go template
+// makes of the items.
+package render
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"github.com/mattn/go-isatty"
+)
+
+// Renderer writes items out in one output mode
+type Renderer interface {
+	// RenderItem writes a single item, as asked for by todo get
+	RenderItem(w io.Writer, item db.ToDoItem) error
+
+	// RenderItems writes a listing of items
+	RenderItems(w io.Writer, items []db.ToDoItem) error
+}
+
+// DocumentRenderer is implemented by the renderers of data formats, JSON
+// and YAML, which can also write values that are not a list of items,
+// like an agenda, as a single document
+type DocumentRenderer interface {
+	RenderDocument(w io.Writer, v any) error
+}
+
+// The output modes New knows about.  A template is given along with the
+// mode, as in template={{.Id}} {{.Title}}.
+const (
+	Auto     = "auto"
+	Table    = "table"
+	Plain    = "plain"
+	JSON     = "json"
+	YAML     = "yaml"
+	Template = "template"
+)
+
+// Modes lists every output mode, for help texts and error messages
+var Modes = []string{Auto, Table, Plain, JSON, YAML, Template + "=..."}
+
+// New returns the renderer for an output mode.  Auto picks a table when
+// out is a terminal and JSON otherwise, so scripts that read the output
+// keep getting JSON.  Color is used for tables and plain output when out
+// is a terminal and the NO_COLOR environment variable is not set.
+func New(mode string, out *os.File) (Renderer, error) {
+	terminal := isTerminal(out)
+	color := terminal && os.Getenv("NO_COLOR") == ""
+
+	name, arg, _ := strings.Cut(mode, "=")
+	switch strings.ToLower(name) {
+	case Auto, "":
+		if terminal {
+			return tableRenderer{color: color}, nil
+		}
+		return jsonRenderer{}, nil
+	case Table:
+		return tableRenderer{color: color}, nil
+	case Plain:
+		return plainRenderer{color: color}, nil
+	case JSON:
+		return jsonRenderer{}, nil
+	case YAML:
+		return yamlRenderer{}, nil
+	case Template:
+		return newTemplateRenderer(arg)
+	default:
+		return nil, fmt.Errorf("invalid output %q, use one of %s", mode, strings.Join(Modes, ", "))
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	return f != nil && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
@@ -0,0 +1,95 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"drexel.edu/todo/db"
+)
+
+// tableRenderer lines the items up in columns under a header
+type tableRenderer struct {
+	color bool
+}
+
+var tableHeader = []string{"ID", "", "TITLE", "LIST", "DUE", "PRIORITY", "TAGS"}
+
+func (r tableRenderer) RenderItem(w io.Writer, item db.ToDoItem) error {
+	return r.RenderItems(w, []db.ToDoItem{item})
+}
+
+func (r tableRenderer) RenderItems(w io.Writer, items []db.ToDoItem) error {
+	rows := [][]string{tableHeader}
+	for _, item := range items {
+		rows = append(rows, tableRow(item))
+	}
+
+	//Work out the column widths from the plain text, color codes are
+	//added afterwards so they do not throw the alignment off
+	widths := make([]int, len(tableHeader))
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	now := time.Now()
+	for i, row := range rows {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+		}
+		line := strings.TrimRight(strings.Join(cells, "  "), " ")
+
+		if r.color && i > 0 {
+			line = paint(line, itemColor(items[i-1], now))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tableRow(item db.ToDoItem) []string {
+	row := []string{strconv.Itoa(item.Id), checkbox(item), OneLine(item.Title), item.List, "", "", strings.Join(item.Tags, ",")}
+	if item.Due != nil {
+		row[4] = formatDue(*item.Due)
+	}
+	if item.Priority != db.PriorityNone {
+		row[5] = item.Priority.String()
+	}
+
+	return row
+}
+
+func checkbox(item db.ToDoItem) string {
+	if item.IsDone {
+		return "[x]"
+	}
+
+	return "[ ]"
+}
+
+// formatDue leaves the time off due dates at midnight
+func formatDue(due time.Time) string {
+	due = due.Local()
+	if due.Hour() == 0 && due.Minute() == 0 {
+		return due.Format("2006-01-02")
+	}
+
+	return due.Format("2006-01-02 15:04")
+}
+
+// OneLine squashes a title onto a single line, for formats that show an
+// item per line
+func OneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// templateRenderer runs a Go template once per item, each followed by a
+// newline.  The template sees a db.ToDoItem, so {{.Id}}, {{.Title}},
+// {{.IsDone}} and so on, plus a few helper functions:
+//
+//	{{join .Tags ","}}  {{checkbox .}}  {{line .}}  {{date .Due}}
+type templateRenderer struct {
+	tmpl *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"checkbox": checkbox,
+	"line":     Line,
+	"date":     formatDate,
+}
+
+// formatDate is the date template function, it accepts the item's
+// optional (pointer) dates as well as the plain ones and writes nothing
+// for a missing date
+func formatDate(t any) string {
+	switch t := t.(type) {
+	case time.Time:
+		return formatDue(t)
+	case *time.Time:
+		if t != nil {
+			return formatDue(*t)
+		}
+	}
+
+	return ""
+}
+
+func newTemplateRenderer(text string) (Renderer, error) {
+	if text == "" {
+		return nil, errors.New("the template output needs a template, as in template='{{.Id}} {{.Title}}'")
+	}
+
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return templateRenderer{tmpl: tmpl}, nil
+}
+
+func (r templateRenderer) RenderItem(w io.Writer, item db.ToDoItem) error {
+	return r.RenderItems(w, []db.ToDoItem{item})
+}
+
+func (r templateRenderer) RenderItems(w io.Writer, items []db.ToDoItem) error {
+	for _, item := range items {
+		if err := r.tmpl.Execute(w, item); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}